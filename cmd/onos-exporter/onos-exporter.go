@@ -8,6 +8,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/onosproject/onos-lib-go/pkg/logging"
 
@@ -15,18 +18,6 @@ import (
 	"github.com/onosproject/onos-exporter/pkg/export"
 )
 
-const (
-	endpoint_address          = ":9861"
-	endpoint_path             = "/metrics"
-	exporter_mode             = "prometheus"
-	e2tEndpointDefault        = "onos-e2t:5150"
-	xappPciEndpointDefault    = "onos-pci:5150"
-	xappKpimonEndpointDefault = "onos-kpimon:5150"
-	topoEndpointDefault       = "onos-topo:5150"
-	uenibEndpointDefault      = "onos-uenib:5150"
-	profileTargetsDefault     = ""
-)
-
 var log = logging.GetLogger("main")
 
 var fatalErr error
@@ -37,6 +28,52 @@ func fatal(e error) {
 	fatalErr = e
 }
 
+// collectorFlags defines the prefix and description of the
+// flags of each collector, e.g., e2tEndpoint, e2tInterval and
+// e2tTimeout for the onos e2t collector, and if it has a watch
+// flag, e.g., topoWatch for the onos topo collector.
+var collectorFlags = []struct {
+	name        string
+	prefix      string
+	description string
+	watch       bool
+}{
+	{config.ONOSE2T, "e2t", "E2T", false},
+	{config.ONOSXAPPPCI, "xappPci", "XApp PCI", false},
+	{config.ONOSXAPPKPIMON, "xappKpimon", "XApp Kpimon", false},
+	{config.ONOSTOPO, "topo", "Onos topo", true},
+	{config.ONOSUENIB, "uenib", "Onos uenib", true},
+	{config.ONOSPROFILE, "profile", "Profile", false},
+}
+
+// disableKPIs disables in cfg the KPIs listed in kpiList, as
+// <collector>.<kpi> separated by comma. The KPIs of unknown collectors
+// are added to cfg, so they are reported by its validation.
+func disableKPIs(cfg *export.Config, kpiList string) {
+	disabled := false
+	for _, kpi := range strings.Split(kpiList, ",") {
+		kpi = strings.TrimSpace(kpi)
+		if kpi == "" {
+			continue
+		}
+		name, kpiName := kpi, ""
+		if i := strings.LastIndex(kpi, "."); i >= 0 {
+			name, kpiName = kpi[:i], kpi[i+1:]
+		}
+
+		colCfg := cfg.CollectorsConfigs[name]
+		kpis := make(map[string]export.KPIConfig, len(colCfg.KPIs)+1)
+		for k, v := range colCfg.KPIs {
+			kpis[k] = v
+		}
+		kpiCfg := kpis[kpiName]
+		kpiCfg.Enabled = &disabled
+		kpis[kpiName] = kpiCfg
+		colCfg.KPIs = kpis
+		cfg.CollectorsConfigs[name] = colCfg
+	}
+}
+
 func main() {
 	defer func() {
 		if fatalErr != nil {
@@ -44,58 +81,137 @@ func main() {
 		}
 	}()
 
-	address := flag.String("address", endpoint_address, "Exporter endpoint address:port or just :port")
-	path := flag.String("path", endpoint_path, "Exporter endpoint path be used to export kpis")
-	mode := flag.String("mode", exporter_mode, "Exporter mode (e.g., prometheus, ...)")
+	defaults := export.DefaultConfig()
+
+	configPath := flag.String("config", "", "Exporter config file (YAML or JSON), the flags set override its values")
+	address := flag.String("address", defaults.Address, "Exporter endpoint address:port or just :port")
+	path := flag.String("path", defaults.Path, "Exporter endpoint path be used to export kpis")
+	mode := flag.String("mode", defaults.Mode, "Exporter mode (e.g., prometheus, ...)")
+	background := flag.Bool("background", defaults.Background, "Poll the collectors in the background, each one on its own interval, and export their latest KPIs")
 	caPath := flag.String("caPath", "", "path to CA certificate")
 	keyPath := flag.String("keyPath", "", "path to client private key")
 	certPath := flag.String("certPath", "", "path to client certificate")
-	e2tEndpoint := flag.String("e2tEndpoint", e2tEndpointDefault, "E2T service endpoint")
-	xappPciEndpoint := flag.String("xappPciEndpoint", xappPciEndpointDefault, "XApp PCI service endpoint")
-	xappKpimonEndpoint := flag.String("xappKpimonEndpoint", xappKpimonEndpointDefault, "XApp Kpimon service endpoint")
-	topoEndpoint := flag.String("topoEndpoint", topoEndpointDefault, "Onos topo service endpoint")
-	uenibEndpoint := flag.String("uenibEndpoint", uenibEndpointDefault, "Onos uenib service endpoint")
-	profileTargets := flag.String("profileTargets", profileTargetsDefault, "Set of sd-ran components (separated by comma) to extract pprof profiles.")
+	webCertPath := flag.String("webCertPath", "", "path to the certificate to serve the exporter endpoint over HTTPS")
+	webKeyPath := flag.String("webKeyPath", "", "path to the private key to serve the exporter endpoint over HTTPS")
+	webClientCAPath := flag.String("webClientCAPath", "", "path to the CA certificate required to sign the client certificates of the exporter endpoint")
+	webUsername := flag.String("webUsername", "", "username of the basic auth of the exporter endpoint")
+	webPasswordFile := flag.String("webPasswordFile", "", "path to the file with the password of the basic auth of the exporter endpoint")
+	webTokenFile := flag.String("webTokenFile", "", "path to the file with the bearer token of the exporter endpoint")
+	detail := flag.String("detail", "", "Detail level of the KPIs: object (a series per object, the default) or aggregate (only the aggregates)")
+	disabledKPIs := flag.String("disabledKPIs", "", "KPIs not exported, as <collector>.<kpi> separated by comma (e.g., onos-topo.relations,onos-profile.pprof)")
+
+	// overrides sets the Config values of the flags set.
+	overrides := map[string]func(cfg *export.Config){
+		"address":    func(cfg *export.Config) { cfg.Address = *address },
+		"path":       func(cfg *export.Config) { cfg.Path = *path },
+		"mode":       func(cfg *export.Config) { cfg.Mode = *mode },
+		"background": func(cfg *export.Config) { cfg.Background = *background },
+		"caPath":     func(cfg *export.Config) { cfg.TLS.CAPath = *caPath },
+		"keyPath":    func(cfg *export.Config) { cfg.TLS.KeyPath = *keyPath },
+		"certPath":   func(cfg *export.Config) { cfg.TLS.CertPath = *certPath },
+
+		"webCertPath":     func(cfg *export.Config) { cfg.Web.CertPath = *webCertPath },
+		"webKeyPath":      func(cfg *export.Config) { cfg.Web.KeyPath = *webKeyPath },
+		"webClientCAPath": func(cfg *export.Config) { cfg.Web.ClientCAPath = *webClientCAPath },
+		"webUsername":     func(cfg *export.Config) { cfg.Web.Username = *webUsername },
+		"webPasswordFile": func(cfg *export.Config) { cfg.Web.PasswordFile = *webPasswordFile },
+		"webTokenFile":    func(cfg *export.Config) { cfg.Web.TokenFile = *webTokenFile },
+
+		"detail":       func(cfg *export.Config) { cfg.Detail = *detail },
+		"disabledKPIs": func(cfg *export.Config) { disableKPIs(cfg, *disabledKPIs) },
+	}
+
+	for _, cf := range collectorFlags {
+		name := cf.name
+		colDefaults := defaults.CollectorsConfigs[name]
+
+		endpointFlag := cf.prefix + "Endpoint"
+		endpointUsage := cf.description + " service endpoint"
+		if name == config.ONOSPROFILE {
+			endpointFlag = "profileTargets"
+			endpointUsage = "Set of sd-ran components (separated by comma) to extract pprof profiles."
+		}
+		endpoint := flag.String(endpointFlag, colDefaults.ServiceAddress, endpointUsage)
+		interval := flag.Duration(cf.prefix+"Interval", colDefaults.Interval, cf.description+" collector poll interval (background mode)")
+		timeout := flag.Duration(cf.prefix+"Timeout", colDefaults.Timeout, cf.description+" collector timeout (0 for no timeout)")
+
+		overrides[endpointFlag] = func(cfg *export.Config) {
+			colCfg := cfg.CollectorsConfigs[name]
+			colCfg.ServiceAddress = *endpoint
+			if name == config.ONOSPROFILE {
+				colCfg.Enabled = *endpoint != ""
+			}
+			cfg.CollectorsConfigs[name] = colCfg
+		}
+		overrides[cf.prefix+"Interval"] = func(cfg *export.Config) {
+			colCfg := cfg.CollectorsConfigs[name]
+			colCfg.Interval = *interval
+			cfg.CollectorsConfigs[name] = colCfg
+		}
+		overrides[cf.prefix+"Timeout"] = func(cfg *export.Config) {
+			colCfg := cfg.CollectorsConfigs[name]
+			colCfg.Timeout = *timeout
+			cfg.CollectorsConfigs[name] = colCfg
+		}
+
+		if cf.watch {
+			watch := flag.Bool(cf.prefix+"Watch", colDefaults.Watch != nil && *colDefaults.Watch, cf.description+" collector keeps a replica up to date with the watch API, instead of listing on every poll")
+			overrides[cf.prefix+"Watch"] = func(cfg *export.Config) {
+				colCfg := cfg.CollectorsConfigs[name]
+				colCfg.Watch = watch
+				cfg.CollectorsConfigs[name] = colCfg
+			}
+		}
+	}
 
 	flag.Parse()
 
 	log.Info("Starting onos-exporter")
 
-	cfgs := map[string]export.CollectorConfig{
-		config.ONOSE2T: {
-			ServiceAddress: *e2tEndpoint,
-		},
-		config.ONOSXAPPPCI: {
-			ServiceAddress: *xappPciEndpoint,
-		},
-		config.ONOSXAPPKPIMON: {
-			ServiceAddress: *xappKpimonEndpoint,
-		},
-		config.ONOSTOPO: {
-			ServiceAddress: *topoEndpoint,
-		},
-		config.ONOSUENIB: {
-			ServiceAddress: *uenibEndpoint,
-		},
-		config.ONOSPROFILE: {
-			ServiceAddress: *profileTargets,
-		},
+	cfg := defaults
+	if *configPath != "" {
+		var err error
+		cfg, err = export.LoadConfig(*configPath)
+		if err != nil {
+			fatal(err)
+			return
+		}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if override, ok := overrides[f.Name]; ok {
+			override(&cfg)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		fatal(err)
+		return
 	}
 
-	cfg := export.Config{
-		Address:           *address,
-		Path:              *path,
-		Mode:              *mode,
-		CAPath:            *caPath,
-		KeyPath:           *keyPath,
-		CertPath:          *certPath,
-		CollectorsConfigs: cfgs,
+	exporter, err := export.NewExporter(cfg)
+	if err != nil {
+		fatal(err)
+		return
 	}
 
-	exporter := export.NewExporter(cfg)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exporter.Run()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
-	if err := exporter.Run(); err != nil {
+	select {
+	case err := <-errCh:
 		log.Errorf("onos exporter error")
 		fatal(err)
+	case sig := <-sigCh:
+		log.Infof("Stopping onos-exporter on signal %s", sig)
+	}
+
+	if err := exporter.Close(); err != nil {
+		log.Errorf("onos exporter close error %s", err)
 	}
 }
@@ -0,0 +1,178 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// modTime returns the latest modification time of the files at paths.
+func modTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// certFile keeps a certificate and its key loaded from files,
+// loading them again when the files change.
+type certFile struct {
+	certPath string
+	keyPath  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertFile(certPath, keyPath string) *certFile {
+	return &certFile{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+}
+
+// certificate returns the certificate, loaded again if its files
+// changed since it was loaded. If it can not be loaded again, the
+// previous certificate is returned.
+func (c *certFile) certificate() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mt, err := modTime(c.certPath, c.keyPath)
+	if err != nil && c.cert == nil {
+		return nil, err
+	}
+	if err != nil || (c.cert != nil && !mt.After(c.modTime)) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		if c.cert == nil {
+			return nil, err
+		}
+		log.Warnf("could not reload certificate %s: %s", c.certPath, err)
+		return c.cert, nil
+	}
+
+	if c.cert != nil {
+		log.Infof("reloaded certificate %s", c.certPath)
+	}
+	c.cert = &cert
+	c.modTime = mt
+	return c.cert, nil
+}
+
+// caFile keeps a pool of CA certificates loaded from a file,
+// loading it again when the file changes.
+type caFile struct {
+	path string
+
+	mu      sync.Mutex
+	certs   *x509.CertPool
+	modTime time.Time
+}
+
+func newCAFile(path string) *caFile {
+	return &caFile{
+		path: path,
+	}
+}
+
+// pool returns the pool of CA certificates, loaded again if its
+// file changed since it was loaded. If it can not be loaded again,
+// the previous pool is returned.
+func (c *caFile) pool() (*x509.CertPool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mt, err := modTime(c.path)
+	if err != nil && c.certs == nil {
+		return nil, err
+	}
+	if err != nil || (c.certs != nil && !mt.After(c.modTime)) {
+		return c.certs, nil
+	}
+
+	certs, err := loadCAPool(c.path)
+	if err != nil {
+		if c.certs == nil {
+			return nil, err
+		}
+		log.Warnf("could not reload CA %s: %s", c.path, err)
+		return c.certs, nil
+	}
+
+	if c.certs != nil {
+		log.Infof("reloaded CA %s", c.path)
+	}
+	c.certs = certs
+	c.modTime = mt
+	return c.certs, nil
+}
+
+func loadCAPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := x509.NewCertPool()
+	if !certs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no CA certificate found in %s", path)
+	}
+	return certs, nil
+}
+
+// verifyPeerCertificate returns a function to verify the certificate
+// chain presented by a server against the pool of CA certificates
+// and the serverName, to be used as tls.Config VerifyPeerCertificate.
+func (c *caFile) verifyPeerCertificate(serverName string) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no certificate presented by %s", serverName)
+		}
+
+		roots, err := c.pool()
+		if err != nil {
+			return err
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, rawCert := range rawCerts {
+			cert, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err = certs[0].Verify(x509.VerifyOptions{
+			DNSName:       serverName,
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// selfSigned returns a self-signed CA certificate valid for name.
+func selfSigned(t *testing.T, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert
+}
+
+func Test_VerifyPeerCertificate(t *testing.T) {
+	cert := selfSigned(t, "onos-topo")
+	other := selfSigned(t, "onos-topo")
+
+	caPath := filepath.Join(t.TempDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	assert.NoError(t, ioutil.WriteFile(caPath, caPEM, 0600))
+
+	ca := newCAFile(caPath)
+	assert.NoError(t, ca.verifyPeerCertificate("onos-topo")([][]byte{cert.Raw}, nil))
+	assert.Error(t, ca.verifyPeerCertificate("onos-e2t")([][]byte{cert.Raw}, nil))
+	assert.Error(t, ca.verifyPeerCertificate("onos-topo")([][]byte{other.Raw}, nil))
+	assert.Error(t, ca.verifyPeerCertificate("onos-topo")(nil, nil))
+
+	_, err := newCAFile(filepath.Join(t.TempDir(), "missing.crt")).pool()
+	assert.Error(t, err)
+}
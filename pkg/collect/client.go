@@ -6,14 +6,44 @@ package collect
 
 import (
 	"crypto/tls"
+	"net"
+	"time"
 
 	"github.com/onosproject/onos-lib-go/pkg/certs"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
 )
 
-// GetConnection returns a gRPC client connection to the onos service
-func GetConnection(address, certPath, keyPath string, noTls bool) (*grpc.ClientConn, error) {
+// connectBackoff defines the backoff used by a connection to
+// reconnect to an onos service, capped to a few scrape intervals.
+var connectBackoff = backoff.Config{
+	BaseDelay:  1.0 * time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   30 * time.Second,
+}
+
+// TLSOptions defines the certificates of a connection to an onos service.
+// CertPath and KeyPath define the client certificate, the default onos
+// client certificate is used if they are not set.
+// CAPath defines the CA certificates used to verify the certificate of
+// the service, which is not verified if CAPath is not set.
+// ServerName overrides the name verified in the service certificate,
+// by default the host of the service address.
+// The certificate files are reloaded when they change on disk, e.g.,
+// when cert-manager rotates them.
+type TLSOptions struct {
+	CAPath     string
+	CertPath   string
+	KeyPath    string
+	ServerName string
+}
+
+// GetConnection returns a gRPC client connection to the onos service.
+// The connection is not blocking, it reconnects with backoff on its own
+// and it is expected to be kept and reused (see connManager).
+func GetConnection(address string, tlsOpts TLSOptions, noTls bool) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 
 	if noTls {
@@ -21,35 +51,74 @@ func GetConnection(address, certPath, keyPath string, noTls bool) (*grpc.ClientC
 			grpc.WithInsecure(),
 		}
 	} else {
-		if certPath != "" && keyPath != "" {
-			cert, err := tls.LoadX509KeyPair(certPath, keyPath)
-			if err != nil {
-				return nil, err
-			}
-			opts = []grpc.DialOption{
-				grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
-					Certificates:       []tls.Certificate{cert},
-					InsecureSkipVerify: true,
-				})),
-			}
-		} else {
-			// Load default Certificates
-			cert, err := tls.X509KeyPair([]byte(certs.DefaultClientCrt), []byte(certs.DefaultClientKey))
-			if err != nil {
-				return nil, err
-			}
-			opts = []grpc.DialOption{
-				grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
-					Certificates:       []tls.Certificate{cert},
-					InsecureSkipVerify: true,
-				})),
-			}
+		tlsConfig, err := clientTLSConfig(address, tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		opts = []grpc.DialOption{
+			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
 		}
 	}
 
+	opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff:           connectBackoff,
+		MinConnectTimeout: 5 * time.Second,
+	}))
+
 	conn, err := grpc.Dial(address, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
+
+// clientTLSConfig defines the tls.Config of a connection to the onos
+// service at address based on tlsOpts.
+func clientTLSConfig(address string, tlsOpts TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if tlsOpts.CertPath != "" && tlsOpts.KeyPath != "" {
+		clientCert := newCertFile(tlsOpts.CertPath, tlsOpts.KeyPath)
+		if _, err := clientCert.certificate(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return clientCert.certificate()
+		}
+	} else {
+		// Load default Certificates
+		cert, err := tls.X509KeyPair([]byte(certs.DefaultClientCrt), []byte(certs.DefaultClientKey))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if tlsOpts.CAPath == "" {
+		log.Warnf("no CA configured for %s, its certificate is not verified", address)
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	serverName := tlsOpts.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		serverName = host
+	}
+
+	ca := newCAFile(tlsOpts.CAPath)
+	if _, err := ca.pool(); err != nil {
+		return nil, err
+	}
+
+	// The certificate of the service is verified by verifyPeerCertificate
+	// instead of the tls package, so the CA is reloaded when it changes.
+	tlsConfig.ServerName = serverName
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = ca.verifyPeerCertificate(serverName)
+
+	return tlsConfig, nil
+}
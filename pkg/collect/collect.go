@@ -5,8 +5,10 @@
 package collect
 
 import (
+	"context"
 	"fmt"
-	"sync"
+	"strconv"
+	"time"
 
 	exporterConfig "github.com/onosproject/onos-exporter/pkg/config"
 	"github.com/onosproject/onos-exporter/pkg/kpis"
@@ -17,72 +19,220 @@ var log = logging.GetLogger("collect")
 
 // Collector defines an interface for Collectors to retrieve
 // a list of kpis.KPI via the Collect method.
+// Collect must give up when ctx is done, returning the kpis.KPI
+// retrieved so far (if any) along with the error.
+// Name returns the name the Collector was created with, and Close
+// releases the resources kept by a Collector between calls of Collect,
+// e.g., its connection to an onos service.
 type Collector interface {
-	Collect() ([]kpis.KPI, error)
+	Collect(ctx context.Context) ([]kpis.KPI, error)
+	Name() string
+	Close() error
 }
 
 type collector struct {
-	name   string
-	config Configuration
+	name     string
+	config   Configuration
+	conns    *connManager
+	exported func(kpi kpis.KPI) bool
 }
 
-func (col *collector) Collect() ([]kpis.KPI, error) {
+// exports reports if any of onosKPIs is exported by the collector, as
+// set by the Exported field of its Options, so the collector skips the
+// RPCs and the computation of the kpis that are not exported.
+func (col *collector) exports(onosKPIs ...kpis.KPI) bool {
+	if col.exported == nil {
+		return true
+	}
+	for _, kpi := range onosKPIs {
+		if col.exported(kpi) {
+			return true
+		}
+	}
+	return false
+}
+
+func (col *collector) Collect(ctx context.Context) ([]kpis.KPI, error) {
 	return []kpis.KPI{}, nil
 }
 
+// withTimeout derives from ctx a context limited by the timeout
+// configured for the collector, if any.
+func (col *collector) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := col.config.getTimeout()
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Name implements the Collector interface behavior for collector.
+func (col *collector) Name() string {
+	return col.name
+}
+
+// Close implements the Collector interface behavior for collector,
+// closing the connection kept by its connection manager.
+func (col *collector) Close() error {
+	if col.conns == nil {
+		return nil
+	}
+	return col.conns.Close()
+}
+
+// Options defines the parameters a collector is created with.
+// ServiceAddress is the address of the onos service the collector
+// retrieves kpis from, and TLS the certificates used to connect to it,
+// unless NoTLS is true. Timeout limits the duration of each Collect
+// call (no limit if zero). Watch enables the watch mode of the
+// collectors that support it (i.e., onos-topo and onos-uenib), which
+// keep a replica of the state of their onos service up to date with
+// its events. NoTLS and Watch are not set if nil, so they can set
+// false over the value loaded from ConfigFile.
+// KPM defines how the kpimon collector exports KPM measurements, and
+// Topo how the topo collector exports the topo objects.
+// ConfigFile optionally defines a file to load the collector config
+// options from (e.g., service-address or no-tls), the other Options
+// that are set override the values loaded from it.
+// Exported reports if a kpi of the collector is exported, called with
+// the kpi returned by its factory (e.g., kpis.XappPciHistory()) before
+// it is computed, all the kpis are exported if nil.
+type Options struct {
+	ServiceAddress string
+	TLS            TLSOptions
+	NoTLS          *bool
+	Timeout        time.Duration
+	Watch          *bool
+	KPM            KPMOptions
+	Topo           TopoOptions
+	ConfigFile     string
+	Exported       func(kpi kpis.KPI) bool
+}
+
+// values returns the Options that are set as the values of
+// the config options.
+func (o Options) values() map[string]string {
+	values := map[string]string{}
+
+	if o.ServiceAddress != "" {
+		values[addressKey] = o.ServiceAddress
+	}
+	if o.TLS.CAPath != "" {
+		values[tlsCAPathKey] = o.TLS.CAPath
+	}
+	if o.TLS.CertPath != "" {
+		values[tlsCertPathKey] = o.TLS.CertPath
+	}
+	if o.TLS.KeyPath != "" {
+		values[tlsKeyPathKey] = o.TLS.KeyPath
+	}
+	if o.TLS.ServerName != "" {
+		values[tlsServerNameKey] = o.TLS.ServerName
+	}
+	if o.NoTLS != nil {
+		values[noTLSKey] = strconv.FormatBool(*o.NoTLS)
+	}
+	if o.Timeout > 0 {
+		values[timeoutKey] = o.Timeout.String()
+	}
+	if o.Watch != nil {
+		values[watchKey] = strconv.FormatBool(*o.Watch)
+	}
+
+	return values
+}
+
 // CreateCollector instantiates a new collector based on the const
 // name of the collector specified. Available collectors must be defined
 // in the cost set of strings.
-func CreateCollector(name, serviceAddress string) (Collector, error) {
-	colConfig := InitConfig(name)
-	err := colConfig.set(map[string]string{addressKey: serviceAddress})
+func CreateCollector(name string, opts Options) (Collector, error) {
+	colConfig := NewConfig(name)
+
+	if opts.ConfigFile != "" {
+		if err := colConfig.load(opts.ConfigFile); err != nil {
+			return &collector{}, fmt.Errorf("could not configure collector %s error %s", name, err)
+		}
+	}
+
+	err := colConfig.set(opts.values())
 
 	if err != nil {
 		return &collector{}, fmt.Errorf("could not configure collector %s error %s", name, err)
 
 	}
 
+	if err := colConfig.validate(); err != nil {
+		return &collector{}, fmt.Errorf("could not configure collector %s error %s", name, err)
+	}
+
 	switch name {
 	case exporterConfig.ONOSE2T:
 		return &onose2tCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				conns:    newConnManager(name, colConfig),
+				exported: opts.Exported,
 			},
+			lifecycle: newE2tLifecycle(),
 		}, nil
 	case exporterConfig.ONOSXAPPKPIMON:
 		return &xappKpimonCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				conns:    newConnManager(name, colConfig),
+				exported: opts.Exported,
 			},
+			kpm:      opts.KPM,
+			exported: newKpmExported(),
 		}, nil
 	case exporterConfig.ONOSXAPPPCI:
 		return &xappPciCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				conns:    newConnManager(name, colConfig),
+				exported: opts.Exported,
 			},
+			history: newPciHistory(),
 		}, nil
 	case exporterConfig.ONOSTOPO:
-		return &onosTopoCollector{
+		topoCollector := &onosTopoCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				conns:    newConnManager(name, colConfig),
+				exported: opts.Exported,
 			},
-		}, nil
+			topo:     opts.Topo,
+			controls: newE2Controls(),
+		}
+		if colConfig.watch() {
+			topoCollector.replica = newTopoReplica(name, topoCollector.conns)
+			topoCollector.replica.start()
+		}
+		return topoCollector, nil
 	case exporterConfig.ONOSUENIB:
-		return &onosUenibCollector{
+		uenibCollector := &onosUenibCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				conns:    newConnManager(name, colConfig),
+				exported: opts.Exported,
 			},
-		}, nil
+		}
+		if colConfig.watch() {
+			uenibCollector.replica = newUenibReplica(name, uenibCollector.conns)
+			uenibCollector.replica.start()
+		}
+		return uenibCollector, nil
 	case exporterConfig.ONOSPROFILE:
 		return &onosProfileCollector{
 			collector: collector{
-				name:   name,
-				config: colConfig,
+				name:     name,
+				config:   colConfig,
+				exported: opts.Exported,
 			},
 		}, nil
 	default:
@@ -91,32 +241,36 @@ func CreateCollector(name, serviceAddress string) (Collector, error) {
 	}
 }
 
-// KPIs retrieves the list of kpis.KPI from each Collector.
-// It handles each collector error locally, logging the error.
-// In any case, kpis.KPI list is returned, e.g., if one collector
-// presents error or even if all collectors present errors.
-func KPIs(collectors []Collector) []kpis.KPI {
-	kpis := []kpis.KPI{}
-	mu := sync.RWMutex{}
-
-	wg := sync.WaitGroup{}
-	wg.Add(len(collectors))
-
-	for _, col := range collectors {
-		go func(c Collector) {
-			colKPIs, err := c.Collect()
-
-			if err != nil {
-				log.Errorf("collector KPIs Collect error: %s", err)
-			} else {
-				mu.Lock()
-				kpis = append(kpis, colKPIs...)
-				mu.Unlock()
-			}
-			wg.Done()
-		}(col)
-	}
-	wg.Wait()
-
-	return kpis
+// failedCollector is the placeholder of a collector that could not be
+// created, so it is still polled and reported down.
+type failedCollector struct {
+	collector
+	err error
+}
+
+// NewFailedCollector returns the placeholder of the collector name
+// that could not be created with err, whose Collect always fails with
+// err.
+func NewFailedCollector(name string, err error) Collector {
+	return &failedCollector{
+		collector: collector{name: name},
+		err:       err,
+	}
+}
+
+// Collect implements the Collector interface behavior for
+// failedCollector, returning the error the collector failed to be
+// created with.
+func (col *failedCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	return []kpis.KPI{}, col.err
+}
+
+// Close closes all the pollers and their collectors, logging the
+// errors of each one.
+func Close(pollers []*Poller) {
+	for _, p := range pollers {
+		if err := p.Close(); err != nil {
+			log.Warnf("collector %s Close error: %s", p.Name(), err)
+		}
+	}
 }
@@ -5,41 +5,66 @@
 package collect
 
 import (
-	"os"
+	"fmt"
+	"strconv"
+	"time"
 
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 )
 
 const (
-	configDir  = ".onos"
 	addressKey = "service-address"
+	timeoutKey = "timeout"
 
-	tlsCertPathKey = "tls.certPath"
-	tlsKeyPathKey  = "tls.keyPath"
-	noTLSKey       = "no-tls"
-	authHeaderKey  = "auth-header"
+	tlsCAPathKey     = "tls.caPath"
+	tlsCertPathKey   = "tls.certPath"
+	tlsKeyPathKey    = "tls.keyPath"
+	tlsServerNameKey = "tls.serverName"
+	noTLSKey         = "no-tls"
+	authHeaderKey    = "auth-header"
+
+	watchKey = "watch"
 )
 
 var configOptions = []string{
-	addressKey,     // The gRPC endpoint
-	tlsCertPathKey, // The path to the TLS certificate
-	tlsKeyPathKey,  // The path to the TLS key
-	noTLSKey,       // If present, do not use TLS
-	authHeaderKey,  // Auth header in the form 'Bearer <base64>'
+	addressKey,       // The gRPC endpoint
+	timeoutKey,       // The timeout of each collection
+	tlsCAPathKey,     // The path to the TLS CA certificate
+	tlsCertPathKey,   // The path to the TLS certificate
+	tlsKeyPathKey,    // The path to the TLS key
+	tlsServerNameKey, // The name verified in the server certificate
+	noTLSKey,         // If true, do not use TLS
+	authHeaderKey,    // Auth header in the form 'Bearer <base64>'
+	watchKey,         // If true, keep a replica up to date with a watch
+}
+
+// boolOptions are the config options with a boolean value, parsed
+// by strconv.ParseBool (e.g., true, false, 1 or 0).
+var boolOptions = []string{
+	noTLSKey,
+	watchKey,
 }
 
 // Configuration defines the methods expected to fulfill
 // the behavior of a config.
 type Configuration interface {
-	init()
+	load(path string) error
 	set(map[string]string) error
+	validate() error
 	getAddress() string
+	getTimeout() time.Duration
+	getCAPath() string
 	getCertPath() string
 	getKeyPath() string
+	getServerName() string
 	noTLS() bool
+	watch() bool
 }
 
+// NewConfig creates an in-memory Configuration for the collector
+// subsystem, with all its options empty.
+// Each collector has its own Configuration, which does not touch
+// the filesystem unless it is explicitly loaded from a file.
 func NewConfig(subsystem string) Configuration {
 	opts := make(map[string]string)
 	for _, optName := range configOptions {
@@ -52,39 +77,90 @@ func NewConfig(subsystem string) Configuration {
 	}
 }
 
-// config implements the Configuration interface, using the
-// viper package to maintain the state of its data.
+// config implements the Configuration interface, keeping
+// the state of its data in memory.
 type config struct {
 	subsystem string
 	options   map[string]string
 }
 
-func (c config) init() {
+// load reads the config options from the YAML (or JSON) file at path,
+// e.g., ~/.onos/<subsystem>.yaml, using its own viper instance.
+// The file is only read, never written.
+func (c config) load(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("could not read %s config file %s: %s", c.subsystem, path, err)
+	}
+
 	for opt := range c.options {
-		c.options[opt] = viper.GetString(opt)
+		if v.IsSet(opt) {
+			c.options[opt] = v.GetString(opt)
+		}
 	}
+
+	return nil
 }
 
 func (c config) set(options map[string]string) error {
 	for opt, value := range options {
-		if _, ok := c.options[opt]; ok {
-			c.options[opt] = value
-			viper.Set(opt, value)
+		if _, ok := c.options[opt]; !ok {
+			return fmt.Errorf("unknown %s config option %s", c.subsystem, opt)
 		}
+		c.options[opt] = value
 	}
-	if err := viper.WriteConfig(); err != nil {
-		return err
+
+	return nil
+}
+
+// validate checks that the boolean config options are set to a value
+// parsed by strconv.ParseBool, so no-tls: false does not disable TLS,
+// and that the timeout is a duration that is not negative, so an
+// invalid timeout is not taken as no timeout.
+func (c config) validate() error {
+	for _, opt := range boolOptions {
+		value := c.options[opt]
+		if value == "" {
+			continue
+		}
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid %s config option %s: %q is not a boolean", c.subsystem, opt, value)
+		}
+	}
+
+	if value := c.options[timeoutKey]; value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("invalid %s config option %s: %q is not a duration that is not negative (e.g., 10s)", c.subsystem, timeoutKey, value)
+		}
 	}
 
 	return nil
 }
 
+// boolOption returns the value of the boolean config option opt,
+// false if it is not set or not a boolean.
+func (c config) boolOption(opt string) bool {
+	value, err := strconv.ParseBool(c.options[opt])
+	return err == nil && value
+}
+
 func (c config) getAddress() string {
-	address := c.options[addressKey]
-	if address == "" {
-		return viper.GetString(addressKey)
+	return c.options[addressKey]
+}
+
+func (c config) getTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.options[timeoutKey])
+	if err != nil {
+		return 0
 	}
-	return address
+	return timeout
+}
+
+func (c config) getCAPath() string {
+	caPath := c.options[tlsCAPathKey]
+	return caPath
 }
 
 func (c config) getCertPath() string {
@@ -97,64 +173,26 @@ func (c config) getKeyPath() string {
 	return keyPath
 }
 
-func (c config) noTLS() bool {
-	tls := c.options[noTLSKey]
-
-	if tls == "" {
-		return false
-	} else {
-		return true
-	}
+func (c config) getServerName() string {
+	serverName := c.options[tlsServerNameKey]
+	return serverName
 }
 
-func runConfigInitCommand(configName string) error {
-	if err := viper.ReadInConfig(); err == nil {
-		return nil
-	}
-
-	home, err := homedir.Dir()
-	if err != nil {
-		return err
-	}
-
-	if err := os.MkdirAll(home+"/"+configDir, 0777); err != nil {
-		return err
-	}
-
-	filePath := home + "/" + configDir + "/" + configName + ".yaml"
-	f, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	_ = f.Close()
-
-	if err := viper.WriteConfig(); err != nil {
-		return err
-	}
-
-	return nil
+func (c config) noTLS() bool {
+	return c.boolOption(noTLSKey)
 }
 
-// InitConfig defines the Configuration to be used for the
-// creation of a connection to a onos service.
-func InitConfig(configNameInit string) Configuration {
-	home, err := homedir.Dir()
-	if err != nil {
-		panic(err)
-	}
-	viper.SetConfigName(configNameInit)
-	viper.AddConfigPath(home + "/" + configDir)
-	viper.AddConfigPath("/etc/onos")
-	viper.AddConfigPath(".")
+func (c config) watch() bool {
+	return c.boolOption(watchKey)
+}
 
-	err = runConfigInitCommand(configNameInit)
-	if err != nil {
-		panic(err)
+// ValidateConfigFile checks the config options of the collector name
+// in the file at path, as they are loaded from the ConfigFile of its
+// Options.
+func ValidateConfigFile(name, path string) error {
+	c := NewConfig(name)
+	if err := c.load(path); err != nil {
+		return err
 	}
-
-	_ = viper.ReadInConfig()
-
-	config := NewConfig(configNameInit)
-	config.init()
-	return config
+	return c.validate()
 }
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ConfigIsolation(t *testing.T) {
+	topo := NewConfig("onos-topo")
+	e2t := NewConfig("onos-e2t")
+
+	assert.NoError(t, topo.set(map[string]string{addressKey: "onos-topo:5150", timeoutKey: "3s"}))
+	assert.NoError(t, e2t.set(map[string]string{addressKey: "onos-e2t:5150"}))
+
+	assert.Equal(t, "onos-topo:5150", topo.getAddress())
+	assert.Equal(t, 3*time.Second, topo.getTimeout())
+	assert.Equal(t, "onos-e2t:5150", e2t.getAddress())
+	assert.Equal(t, time.Duration(0), e2t.getTimeout())
+
+	assert.Error(t, e2t.set(map[string]string{"service-adress": "onos-e2t:5150"}))
+}
+
+func Test_ConfigLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "onos-uenib.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("service-address: onos-uenib:5150\nno-tls: true\n"), 0444))
+
+	c := NewConfig("onos-uenib")
+	assert.NoError(t, c.load(path))
+	assert.Equal(t, "onos-uenib:5150", c.getAddress())
+	assert.True(t, c.noTLS())
+
+	assert.Error(t, c.load(filepath.Join(t.TempDir(), "missing.yaml")))
+}
+
+func Test_ConfigBoolOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "onos-topo.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("no-tls: false\nwatch: 1\n"), 0444))
+
+	c := NewConfig("onos-topo")
+	assert.NoError(t, c.load(path))
+	assert.NoError(t, c.validate())
+	assert.False(t, c.noTLS())
+	assert.True(t, c.watch())
+	assert.NoError(t, ValidateConfigFile("onos-topo", path))
+
+	path = filepath.Join(dir, "onos-e2t.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("no-tls: maybe\n"), 0444))
+	assert.EqualError(t, ValidateConfigFile("onos-e2t", path), `invalid onos-e2t config option no-tls: "maybe" is not a boolean`)
+}
+
+func Test_ConfigTimeout(t *testing.T) {
+	dir := t.TempDir()
+	for content, valid := range map[string]bool{
+		"timeout: 10s\n":  true,
+		"timeout: 10\n":   false,
+		"timeout: 10 s\n": false,
+		"timeout: -1s\n":  false,
+	} {
+		path := filepath.Join(dir, "onos-topo.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		err := ValidateConfigFile("onos-topo", path)
+		if valid {
+			assert.NoError(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+		}
+	}
+}
+
+func Test_OptionsOverrideConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "onos-topo.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("no-tls: true\nwatch: true\n"), 0444))
+
+	c := NewConfig("onos-topo")
+	assert.NoError(t, c.load(path))
+	assert.NoError(t, c.set(Options{}.values()))
+	assert.True(t, c.noTLS())
+	assert.True(t, c.watch())
+
+	noTLS, watch := false, false
+	assert.NoError(t, c.set(Options{NoTLS: &noTLS, Watch: &watch}.values()))
+	assert.False(t, c.noTLS())
+	assert.False(t, c.watch())
+}
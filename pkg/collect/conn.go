@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// connManager keeps a long-lived gRPC client connection to the onos
+// service of a collector, so scrapes reuse the same connection instead
+// of dialing (and handshaking TLS) on every Collect.
+// Reconnection with backoff is performed by the gRPC channel itself,
+// connManager watches its connectivity state and only dials again if
+// the connection was shut down.
+type connManager struct {
+	name   string
+	config Configuration
+
+	mu     sync.Mutex
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+	closed bool
+}
+
+func newConnManager(name string, config Configuration) *connManager {
+	return &connManager{
+		name:   name,
+		config: config,
+	}
+}
+
+// getConnection returns the connection kept by the manager, dialing
+// the service address of the collector config if there is none yet.
+// A connection in transient failure has its reconnect backoff reset,
+// so a scrape does not wait for the whole backoff period to expire.
+func (m *connManager) getConnection() (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, fmt.Errorf("%s connection manager is closed", m.name)
+	}
+
+	if m.conn != nil {
+		switch m.conn.GetState() {
+		case connectivity.Shutdown:
+			_ = m.release()
+		case connectivity.TransientFailure:
+			m.conn.ResetConnectBackoff()
+			return m.conn, nil
+		default:
+			return m.conn, nil
+		}
+	}
+
+	conn, err := GetConnection(
+		m.config.getAddress(),
+		TLSOptions{
+			CAPath:     m.config.getCAPath(),
+			CertPath:   m.config.getCertPath(),
+			KeyPath:    m.config.getKeyPath(),
+			ServerName: m.config.getServerName(),
+		},
+		m.config.noTLS(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.conn = conn
+	m.cancel = cancel
+	go m.watch(ctx, conn)
+
+	return conn, nil
+}
+
+// watch logs the connectivity state changes of conn until it is
+// shut down or the manager releases it.
+func (m *connManager) watch(ctx context.Context, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(ctx, state) {
+		state = conn.GetState()
+
+		switch state {
+		case connectivity.TransientFailure:
+			log.Warnf("%s connection to %s in transient failure", m.name, conn.Target())
+		case connectivity.Shutdown:
+			log.Infof("%s connection to %s shut down", m.name, conn.Target())
+			return
+		default:
+			log.Debugf("%s connection to %s state %s", m.name, conn.Target(), state)
+		}
+	}
+}
+
+// release stops watching and closes the current connection.
+// It must be called with m.mu held.
+func (m *connManager) release() error {
+	if m.conn == nil {
+		return nil
+	}
+	m.cancel()
+	err := m.conn.Close()
+	m.conn = nil
+	m.cancel = nil
+	return err
+}
+
+// Close closes the connection kept by the manager, any later call
+// to getConnection returns an error.
+func (m *connManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	return m.release()
+}
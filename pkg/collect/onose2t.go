@@ -7,6 +7,7 @@ package collect
 import (
 	"context"
 	"fmt"
+	"time"
 
 	subapi "github.com/onosproject/onos-api/go/onos/e2t/e2/v1beta1"
 	"github.com/onosproject/onos-exporter/pkg/kpis"
@@ -15,8 +16,11 @@ import (
 
 // onose2tCollector is the onos e2t collector.
 // It extracts all the e2t related kpis using the Collect method.
+// It keeps the e2t subscriptions across collects, to export their
+// lifecycle.
 type onose2tCollector struct {
 	collector
+	lifecycle *e2tLifecycle
 }
 
 // Collect implements the collector of the onos e2t service kpis.
@@ -25,69 +29,113 @@ type onose2tCollector struct {
 // This function can create go routines if needed in order to extract multiple
 // onos e2t kpis using the same connection and multiple calls to functions
 // defined in the file onose2t.go.
-func (col *onose2tCollector) Collect() ([]kpis.KPI, error) {
+func (col *onose2tCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exportsSubscriptions := col.exports(kpis.OnosE2tSubscriptions(), kpis.OnosE2tSubscriptionCounts(), kpis.OnosE2tSubscriptionLifecycle())
+	exportsChannels := col.exports(kpis.OnosE2tChannels(), kpis.OnosE2tChannelCounts())
+
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("Onose2tCollector Collect missing service address")
 	}
 
-	conn, err := GetConnection(
-		col.config.getAddress(),
-		col.config.getCertPath(),
-		col.config.getKeyPath(),
-		col.config.noTLS(),
-	)
+	conn, err := col.conns.getConnection()
 	if err != nil {
 		return kpis, err
 	}
-	defer conn.Close()
 
-	e2tsubscriptionKPI, err := onose2tListSubscriptions(conn)
-	if err != nil {
-		return kpis, err
+	if exportsSubscriptions {
+		e2tsubscriptionKPIs, err := onose2tListSubscriptions(ctx, conn, col.lifecycle)
+		if err != nil {
+			return kpis, err
+		}
+
+		kpis = append(kpis, e2tsubscriptionKPIs...)
 	}
 
-	kpis = append(kpis, e2tsubscriptionKPI)
+	if exportsChannels {
+		e2tchannelKPIs, err := onose2tListChannels(ctx, conn)
+		if err != nil {
+			return kpis, err
+		}
+
+		kpis = append(kpis, e2tchannelKPIs...)
+	}
 
 	return kpis, nil
 }
 
 // onose2tListSubscriptions implements the extraction of the kpi OnosE2tSubscriptions
 // from the component onose2t. It connects to onos e2t service list the e2NodeSubs
-// and fill the proper fields of the OnosE2tSubscriptionsKPI.
+// and fill the proper fields of the OnosE2tSubscriptionsKPI, and of its
+// aggregate OnosE2tSubscriptionCounts KPI, along with the
+// OnosE2tSubscriptionLifecycle KPI updated by lifecycle.
 // Other functions must be implemented similar to this one in order to extract other
 // kpis from onos e2t service.
-func onose2tListSubscriptions(conn *grpc.ClientConn) (kpis.KPI, error) {
+func onose2tListSubscriptions(ctx context.Context, conn *grpc.ClientConn, lifecycle *e2tLifecycle) ([]kpis.KPI, error) {
 	OnosE2tSubsKPI := kpis.OnosE2tSubscriptions()
 	OnosE2tSubsKPI.Subs = make(map[string]kpis.E2tSubscription)
+	OnosE2tSubCountsKPI := kpis.OnosE2tSubscriptionCounts()
 
 	client := subapi.NewSubscriptionAdminServiceClient(conn)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	response, err := client.ListSubscriptions(ctx, &subapi.ListSubscriptionsRequest{})
 	if err != nil {
-		return OnosE2tSubsKPI, err
-	}
-
-	if err != nil {
-		return OnosE2tSubsKPI, err
+		return nil, err
 	}
 
 	for _, sub := range response.Subscriptions {
 
-		OnosE2tSubsKPI.Subs[string(sub.ID)] = kpis.E2tSubscription{
+		e2tSub := kpis.E2tSubscription{
 			Id:                  string(sub.ID),
-			Revision:            string(rune(sub.Revision)),
+			Revision:            uint64(sub.Revision),
 			ServiceModelName:    string(sub.SubscriptionMeta.ServiceModel.Name),
 			ServiceModelVersion: string(sub.SubscriptionMeta.ServiceModel.Version),
 			E2NodeID:            string(sub.SubscriptionMeta.E2NodeID),
 			Encoding:            sub.SubscriptionMeta.Encoding.String(),
 			StatusPhase:         sub.Status.Phase.String(),
 			StatusState:         sub.Status.State.String(),
+			Channels:            len(sub.Status.Channels),
+		}
+		OnosE2tSubsKPI.Subs[e2tSub.Id] = e2tSub
+		OnosE2tSubCountsKPI.Add(e2tSub.ServiceModelName, e2tSub.ServiceModelVersion, e2tSub.StatusPhase, e2tSub.StatusState)
+	}
+
+	lifecycleKPI := lifecycle.update(time.Now(), OnosE2tSubsKPI.Subs)
+
+	return []kpis.KPI{OnosE2tSubsKPI, OnosE2tSubCountsKPI, lifecycleKPI}, nil
+}
+
+// onose2tListChannels implements the extraction of the kpi OnosE2tChannels
+// from the component onose2t. It connects to onos e2t service to list the
+// channels opened by the apps and fill the proper fields of the
+// OnosE2tChannels KPI, and of its aggregate OnosE2tChannelCounts KPI.
+func onose2tListChannels(ctx context.Context, conn *grpc.ClientConn) ([]kpis.KPI, error) {
+	channelsKPI := kpis.OnosE2tChannels()
+	channelsKPI.Channels = make(map[string]kpis.E2tChannel)
+	channelCountsKPI := kpis.OnosE2tChannelCounts()
+
+	client := subapi.NewSubscriptionAdminServiceClient(conn)
+	response, err := client.ListChannels(ctx, &subapi.ListChannelsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range response.Channels {
+		e2tChannel := kpis.E2tChannel{
+			Id:               string(channel.ID),
+			AppID:            string(channel.AppID),
+			AppInstanceID:    string(channel.AppInstanceID),
+			E2NodeID:         string(channel.E2NodeID),
+			SubscriptionID:   string(channel.SubscriptionID),
+			ServiceModelName: string(channel.ServiceModel.Name),
+			StatusPhase:      channel.Status.Phase.String(),
+			StatusState:      channel.Status.State.String(),
 		}
+		channelsKPI.Channels[e2tChannel.Id] = e2tChannel
+		channelCountsKPI.Add(e2tChannel.AppID, e2tChannel.E2NodeID, e2tChannel.StatusPhase, e2tChannel.StatusState)
 	}
 
-	return OnosE2tSubsKPI, nil
+	return []kpis.KPI{channelsKPI, channelCountsKPI}, nil
 }
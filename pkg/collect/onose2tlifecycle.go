@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"sync"
+	"time"
+
+	subapi "github.com/onosproject/onos-api/go/onos/e2t/e2/v1beta1"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// e2tSubscription keeps the state of an e2t subscription seen by the
+// e2t collector.
+type e2tSubscription struct {
+	serviceModel string
+	firstSeen    time.Time
+	notOpenSince time.Time
+	failed       bool
+}
+
+// e2tLifecycle keeps the e2t subscriptions across collects, to count
+// the subscriptions created, deleted and failed by diffing them, and
+// to tell the age of each subscription and the time since it left
+// the OPEN phase.
+// The subscriptions of the first collect are not counted as created
+// (or failed), they are the baseline the next collects are diffed to.
+type e2tLifecycle struct {
+	mu      sync.Mutex
+	subs    map[string]*e2tSubscription
+	created map[string]uint64
+	deleted map[string]uint64
+	failed  map[string]uint64
+}
+
+func newE2tLifecycle() *e2tLifecycle {
+	return &e2tLifecycle{
+		created: make(map[string]uint64),
+		deleted: make(map[string]uint64),
+		failed:  make(map[string]uint64),
+	}
+}
+
+// update receives the e2t subscriptions at time now, setting the Age
+// and NotOpen of each of them, and returns the subscriptions created,
+// deleted and failed so far according to the data structure of the
+// kpis.OnosE2tSubscriptionLifecycle KPI.
+// A subscription fails when it enters the FAILED state.
+func (l *e2tLifecycle) update(now time.Time, e2tSubs map[string]kpis.E2tSubscription) kpis.KPI {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	baseline := l.subs == nil
+	subs := make(map[string]*e2tSubscription, len(e2tSubs))
+	for id, e2tSub := range e2tSubs {
+		sub, ok := l.subs[id]
+		if !ok {
+			sub = &e2tSubscription{serviceModel: e2tSub.ServiceModelName, firstSeen: now}
+			if !baseline {
+				l.created[sub.serviceModel]++
+			}
+		}
+		subs[id] = sub
+
+		failed := e2tSub.StatusState == subapi.SubscriptionState_SUBSCRIPTION_FAILED.String()
+		if failed && !sub.failed && (ok || !baseline) {
+			l.failed[sub.serviceModel]++
+		}
+		sub.failed = failed
+
+		if e2tSub.StatusPhase == subapi.SubscriptionPhase_SUBSCRIPTION_OPEN.String() {
+			sub.notOpenSince = time.Time{}
+		} else if sub.notOpenSince.IsZero() {
+			sub.notOpenSince = now
+		}
+
+		e2tSub.Age = now.Sub(sub.firstSeen).Seconds()
+		if !sub.notOpenSince.IsZero() {
+			e2tSub.NotOpen = now.Sub(sub.notOpenSince).Seconds()
+		}
+		e2tSubs[id] = e2tSub
+	}
+
+	for id, sub := range l.subs {
+		if _, ok := subs[id]; !ok {
+			l.deleted[sub.serviceModel]++
+		}
+	}
+	l.subs = subs
+
+	lifecycleKPI := kpis.OnosE2tSubscriptionLifecycle()
+	lifecycleKPI.Created = copyCounters(l.created)
+	lifecycleKPI.Deleted = copyCounters(l.deleted)
+	lifecycleKPI.Failed = copyCounters(l.failed)
+	return lifecycleKPI
+}
+
+// copyCounters returns a copy of counters, so it can be formatted
+// while the counters are updated by another collect.
+func copyCounters(counters map[string]uint64) map[string]uint64 {
+	copied := make(map[string]uint64, len(counters))
+	for key, value := range counters {
+		copied[key] = value
+	}
+	return copied
+}
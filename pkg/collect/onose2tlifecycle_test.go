@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"testing"
+	"time"
+
+	subapi "github.com/onosproject/onos-api/go/onos/e2t/e2/v1beta1"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_E2tLifecycle(t *testing.T) {
+	open := subapi.SubscriptionPhase_SUBSCRIPTION_OPEN.String()
+	closed := subapi.SubscriptionPhase_SUBSCRIPTION_CLOSED.String()
+	failed := subapi.SubscriptionState_SUBSCRIPTION_FAILED.String()
+	sub := func(id, phase, state string) kpis.E2tSubscription {
+		return kpis.E2tSubscription{Id: id, ServiceModelName: "oran-e2sm-kpm", StatusPhase: phase, StatusState: state}
+	}
+
+	lifecycle := newE2tLifecycle()
+	t1 := time.Unix(1630000000, 0)
+
+	// The first subscriptions are the baseline.
+	subs := map[string]kpis.E2tSubscription{
+		"1": sub("1", open, ""),
+		"2": sub("2", closed, failed),
+	}
+	values := kpiValues(t, lifecycle.update(t1, subs))
+	assert.Equal(t, 0.0, values["onos_e2t_subscriptions_created_total"])
+	assert.Equal(t, 0.0, values["onos_e2t_subscriptions_failed_total"])
+
+	subs = map[string]kpis.E2tSubscription{
+		"1": sub("1", closed, failed),
+		"3": sub("3", closed, ""),
+	}
+	values = kpiValues(t, lifecycle.update(t1.Add(10*time.Second), subs))
+	assert.Equal(t, 1.0, values["onos_e2t_subscriptions_created_total"])
+	assert.Equal(t, 1.0, values["onos_e2t_subscriptions_deleted_total"])
+	assert.Equal(t, 1.0, values["onos_e2t_subscriptions_failed_total"])
+	assert.Equal(t, 10.0, subs["1"].Age)
+	assert.Equal(t, 0.0, subs["1"].NotOpen)
+	assert.Equal(t, 0.0, subs["3"].Age)
+
+	subs = map[string]kpis.E2tSubscription{
+		"1": sub("1", closed, failed),
+		"3": sub("3", open, ""),
+	}
+	values = kpiValues(t, lifecycle.update(t1.Add(30*time.Second), subs))
+	assert.Equal(t, 1.0, values["onos_e2t_subscriptions_failed_total"])
+	assert.Equal(t, 30.0, subs["1"].Age)
+	assert.Equal(t, 20.0, subs["1"].NotOpen)
+	assert.Equal(t, 0.0, subs["3"].NotOpen)
+}
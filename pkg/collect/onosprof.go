@@ -5,6 +5,7 @@
 package collect
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -36,25 +37,32 @@ func formatAddress(address, format string) (string, error) {
 	}
 }
 
-func (col *onosProfileCollector) Collect() ([]kpis.KPI, error) {
+func (col *onosProfileCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exportsHeap := col.exports(kpis.OnosProfileHeap())
+
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("OnosProfileCollector Collect missing service address(es)")
 	}
 
-	heapKPIs, err := onosProfiles(col.config.getAddress())
-	if err != nil {
-		return kpis, err
+	if !exportsHeap {
+		return kpis, nil
 	}
 
+	heapKPIs, err := onosProfiles(ctx, col.config.getAddress())
 	kpis = append(kpis, heapKPIs)
 
-	return kpis, nil
+	return kpis, err
 
 }
 
-func onosProfiles(addresses string) (kpis.KPI, error) {
+// onosProfiles fetches the profiles of each address, one at a time,
+// until ctx is done. The profiles fetched so far are kept in the
+// returned KPI.
+func onosProfiles(ctx context.Context, addresses string) (kpis.KPI, error) {
 	onosProfileHeapKPI := kpis.OnosProfileHeap()
 	onosProfileHeapKPI.Objects = make(map[string]kpis.HeapObject)
 
@@ -77,6 +85,9 @@ func onosProfiles(addresses string) (kpis.KPI, error) {
 
 	for _, address := range addressesSplit {
 		for _, profileType := range profileTypes {
+			if err := ctx.Err(); err != nil {
+				return onosProfileHeapKPI, err
+			}
 
 			profileAddress, err := getProfile(address, profileType)
 			if err != nil {
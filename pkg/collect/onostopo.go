@@ -7,8 +7,12 @@ package collect
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gogo/protobuf/jsonpb"
 	topoapi "github.com/onosproject/onos-api/go/onos/topo"
@@ -16,102 +20,221 @@ import (
 	"google.golang.org/grpc"
 )
 
+// TopoOptions defines how the topo collector exports the topo
+// objects. Labels lists the keys of the topo labels promoted to
+// Prometheus labels of the entities and relations (e.g., plmnid), and
+// Aspects the aspect types exported as info metrics, with the scalar
+// fields of each aspect as labels (e.g., onos.topo.Location).
+type TopoOptions struct {
+	Labels  []string
+	Aspects []string
+}
+
 // onosTopoCollector is the onos topo collector.
 // It extracts all the topo related kpis using the Collect method.
+// In watch mode the kpis are extracted from a replica of the topo
+// objects, instead of listing them on every Collect.
+// It keeps the state of the E2 control relations across collects,
+// to export their age and the mastership changes of the E2 nodes.
 type onosTopoCollector struct {
 	collector
+	topo     TopoOptions
+	replica  *topoReplica
+	controls *e2Controls
+}
+
+// topoExports defines which kpis of the topo collector are exported,
+// so the topo objects are listed, and the kpis extracted from them,
+// only if they are needed.
+type topoExports struct {
+	entities  bool
+	slices    bool
+	relations bool
+	ran       bool
+	controls  bool
+	aspects   bool
+}
+
+// topoExports returns the topoExports of the collector.
+func (col *onosTopoCollector) topoExports() topoExports {
+	return topoExports{
+		entities:  col.exports(kpis.OnosTopoEntities(), kpis.OnosTopoEntityCounts()),
+		slices:    col.exports(kpis.OnosTopoSlices(), kpis.OnosTopoSliceCounts()),
+		relations: col.exports(kpis.OnosTopoRelations(), kpis.OnosTopoRelationCounts()),
+		ran:       col.exports(kpis.OnosTopoRAN()),
+		controls:  col.exports(kpis.OnosTopoE2Controls()),
+		aspects:   len(col.topo.Aspects) > 0 && col.exports(kpis.OnosTopoAspects()),
+	}
+}
+
+// entityObjects reports if the kpis exported need the topo entities.
+func (e topoExports) entityObjects() bool {
+	return e.entities || e.slices || e.ran || e.controls || e.aspects
+}
+
+// relationObjects reports if the kpis exported need the topo relations.
+func (e topoExports) relationObjects() bool {
+	return e.relations || e.ran || e.controls || e.aspects
 }
 
 // Collect implements the Collector interface behavior for
 // onosTopoCollector, returning a list of kpis.KPI.
-func (col *onosTopoCollector) Collect() ([]kpis.KPI, error) {
+func (col *onosTopoCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exports := col.topoExports()
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("onosTopoCollector Collect missing service address")
 	}
 
-	conn, err := GetConnection(
-		col.config.getAddress(),
-		col.config.getCertPath(),
-		col.config.getKeyPath(),
-		col.config.noTLS(),
-	)
-	if err != nil {
-		return kpis, err
+	if col.replica != nil {
+		return col.collectReplica(ctx, exports, kpis)
 	}
-	defer conn.Close()
 
-	topoEntityObjs, err := getTopoObjects(conn, topoapi.Object_ENTITY)
+	return col.collectList(ctx, exports, kpis)
+}
+
+// collectList appends to kpis the kpis extracted from the topo objects
+// listed from the onos topo service.
+func (col *onosTopoCollector) collectList(ctx context.Context, exports topoExports, kpis []kpis.KPI) ([]kpis.KPI, error) {
+	conn, err := col.conns.getConnection()
 	if err != nil {
 		return kpis, err
 	}
 
-	entitiesKPI := listEntities(topoEntityObjs)
-	slicesKPI := listSlices(topoEntityObjs)
+	var topoEntityObjs, topoRelationObjs []topoapi.Object
+	if exports.entityObjects() {
+		topoEntityObjs, err = getTopoObjects(ctx, conn, topoapi.Object_ENTITY)
+		if err != nil {
+			return kpis, err
+		}
+	}
 
-	kpis = append(kpis, entitiesKPI)
-	kpis = append(kpis, slicesKPI)
+	kpis = col.appendEntityKPIs(kpis, exports, topoEntityObjs)
 
-	relationsKPI, err := listRelations(conn)
-	if err != nil {
-		return kpis, err
+	if exports.relationObjects() {
+		topoRelationObjs, err = getTopoObjects(ctx, conn, topoapi.Object_RELATION)
+		if err != nil {
+			return kpis, err
+		}
 	}
 
-	kpis = append(kpis, relationsKPI)
+	return col.appendRelationKPIs(kpis, exports, topoEntityObjs, topoRelationObjs), nil
+}
+
+// collectReplica appends to kpis the kpis extracted from the topo
+// replica. If the replica is out of sync they are returned along
+// with the error, as partial results. Until the replica is synced for
+// the first time the topo objects are listed instead, so a Collect
+// does not wait for a replica that may never be synced.
+func (col *onosTopoCollector) collectReplica(ctx context.Context, exports topoExports, kpis []kpis.KPI) ([]kpis.KPI, error) {
+	topoEntityObjs, topoRelationObjs, err := col.replica.list()
+	if topoEntityObjs == nil {
+		return col.collectList(ctx, exports, kpis)
+	}
+
+	kpis = col.appendEntityKPIs(kpis, exports, topoEntityObjs)
+	kpis = col.appendRelationKPIs(kpis, exports, topoEntityObjs, topoRelationObjs)
 
 	return kpis, err
 }
 
+// appendEntityKPIs appends to topoKPIs the kpis exported that are
+// extracted from the topo entities alone.
+func (col *onosTopoCollector) appendEntityKPIs(topoKPIs []kpis.KPI, exports topoExports, entities []topoapi.Object) []kpis.KPI {
+	if exports.entities {
+		topoKPIs = append(topoKPIs, listEntities(entities, col.topo)...)
+	}
+	if exports.slices {
+		topoKPIs = append(topoKPIs, listSlices(entities)...)
+	}
+	return topoKPIs
+}
+
+// appendRelationKPIs appends to topoKPIs the kpis exported that are
+// extracted from the topo relations, along with the entities.
+func (col *onosTopoCollector) appendRelationKPIs(topoKPIs []kpis.KPI, exports topoExports, entities, relations []topoapi.Object) []kpis.KPI {
+	if exports.relations {
+		topoKPIs = append(topoKPIs, listRelations(relations, col.topo)...)
+	}
+	if exports.ran {
+		topoKPIs = append(topoKPIs, listRAN(entities, relations))
+	}
+	if exports.controls {
+		topoKPIs = append(topoKPIs, col.controls.list(time.Now(), entities, relations))
+	}
+	if exports.aspects {
+		topoKPIs = append(topoKPIs, listAspects(append(entities, relations...), col.topo))
+	}
+	return topoKPIs
+}
+
+// Close implements the Collector interface behavior for
+// onosTopoCollector, stopping its replica (if any).
+func (col *onosTopoCollector) Close() error {
+	if col.replica != nil {
+		col.replica.stop()
+	}
+	return col.collector.Close()
+}
+
 // getTopoObjects gets topo objects based on type, which
 // can be topoapi.Object_ENTITY or topoapi.Object_RELATION.
-func getTopoObjects(conn *grpc.ClientConn, objType topoapi.Object_Type) ([]topoapi.Object, error) {
-	entitiesKPI := kpis.OnosTopoEntities()
-	entitiesKPI.Entities = make(map[string]kpis.TopoEntity)
-
+func getTopoObjects(ctx context.Context, conn *grpc.ClientConn, objType topoapi.Object_Type) ([]topoapi.Object, error) {
 	filters := &topoapi.Filters{}
 	filters.ObjectTypes = []topoapi.Object_Type{objType}
-	objects, err := listObjects(conn, filters)
+	objects, err := listObjects(ctx, conn, filters)
 
 	return objects, err
 }
 
 // listEntities receives a list of topo Objects and store them according to the
-// data structure of the kpis.OnosTopoEntities KPI.
-func listEntities(objects []topoapi.Object) kpis.KPI {
+// data structure of the kpis.OnosTopoEntities KPI, with the topo labels
+// promoted in opts, and of its aggregate kpis.OnosTopoEntityCounts KPI.
+func listEntities(objects []topoapi.Object, opts TopoOptions) []kpis.KPI {
 	entitiesKPI := kpis.OnosTopoEntities()
 	entitiesKPI.Entities = make(map[string]kpis.TopoEntity)
+	entitiesKPI.PromotedLabels = opts.Labels
+	countsKPI := kpis.OnosTopoEntityCounts()
 
 	for _, object := range objects {
-		entity := parseObjectEntity(object)
+		entity := parseObjectEntity(object, opts.Labels)
 		entitiesKPI.Entities[entity.ID] = entity
+		countsKPI.Add(entity.Kind)
 	}
 
-	return entitiesKPI
+	return []kpis.KPI{entitiesKPI, countsKPI}
 }
 
 // listSlices receives a list of topo Objects and store them according to the
-// data structure of the kpis.OnosTopoSlices KPI.
-func listSlices(objects []topoapi.Object) kpis.KPI {
+// data structure of the kpis.OnosTopoSlices KPI, and of its aggregate
+// kpis.OnosTopoSliceCounts KPI.
+func listSlices(objects []topoapi.Object) []kpis.KPI {
 	slicesKPI := kpis.OnosTopoSlices()
 	slicesKPI.Slices = make(map[string]kpis.TopoEntitySlice)
+	countsKPI := kpis.OnosTopoSliceCounts()
 
 	for _, object := range objects {
 		entitySlices := parseSlicesEntity(object)
 
 		for _, entitySlice := range entitySlices {
 			sliceKey := fmt.Sprintf("%s-%s", entitySlice.NodeID, entitySlice.SliceID)
+			if _, ok := slicesKPI.Slices[sliceKey]; !ok {
+				countsKPI.Add(entitySlice.SliceType, entitySlice.SchedulerType)
+			}
 			slicesKPI.Slices[sliceKey] = entitySlice
 		}
 
 	}
 
-	return slicesKPI
+	return []kpis.KPI{slicesKPI, countsKPI}
 }
 
-func parseObjectEntity(obj topoapi.Object) kpis.TopoEntity {
+func parseObjectEntity(obj topoapi.Object, promoted []string) kpis.TopoEntity {
 	labels := labelsAsCSV(obj)
-	aspects := aspectsAsCSV(obj, false)
+	aspects := aspectsAsCSV(obj)
 
 	var kindID topoapi.ID
 	if e := obj.GetEntity(); e != nil {
@@ -119,92 +242,161 @@ func parseObjectEntity(obj topoapi.Object) kpis.TopoEntity {
 	}
 
 	return kpis.TopoEntity{
-		ID:      string(obj.ID),
-		Kind:    string(kindID),
-		Labels:  labels,
-		Aspects: aspects,
+		ID:       string(obj.ID),
+		Kind:     string(kindID),
+		Labels:   labels,
+		Aspects:  aspects,
+		Promoted: promotedLabels(obj, promoted),
 	}
 }
 
-// listRelations receives a connection to a onos topo service
-// to retrieve the topo Relations and store them according to the
-// data structure of the kpis.OnosTopoRelations KPI.
-func listRelations(conn *grpc.ClientConn) (kpis.KPI, error) {
+// listRelations receives a list of topo Objects and store them according to the
+// data structure of the kpis.OnosTopoRelations KPI, with the topo labels
+// promoted in opts, and of its aggregate kpis.OnosTopoRelationCounts KPI.
+func listRelations(objects []topoapi.Object, opts TopoOptions) []kpis.KPI {
 	relationsKPI := kpis.OnosTopoRelations()
 	relationsKPI.Relations = make(map[string]kpis.TopoRelation)
-
-	filters := &topoapi.Filters{}
-	filters.ObjectTypes = []topoapi.Object_Type{topoapi.Object_RELATION}
-	objects, err := listObjects(conn, filters)
-
-	if err != nil {
-		return relationsKPI, err
-	}
+	relationsKPI.PromotedLabels = opts.Labels
+	countsKPI := kpis.OnosTopoRelationCounts()
 
 	for _, object := range objects {
-		relation := parseObjectRelation(object)
+		relation := parseObjectRelation(object, opts.Labels)
 		relationsKPI.Relations[relation.ID] = relation
+		countsKPI.Add(relation.Kind)
 	}
 
-	return relationsKPI, nil
+	return []kpis.KPI{relationsKPI, countsKPI}
 }
 
-func parseObjectRelation(obj topoapi.Object) kpis.TopoRelation {
+func parseObjectRelation(obj topoapi.Object, promoted []string) kpis.TopoRelation {
 	labels := labelsAsCSV(obj)
-	aspects := aspectsAsCSV(obj, false)
+	aspects := aspectsAsCSV(obj)
 	r := obj.GetRelation()
 
 	return kpis.TopoRelation{
-		ID:      string(obj.ID),
-		Kind:    string(r.KindID),
-		Labels:  labels,
-		Source:  string(r.SrcEntityID),
-		Target:  string(r.TgtEntityID),
-		Aspects: aspects,
+		ID:       string(obj.ID),
+		Kind:     string(r.KindID),
+		Labels:   labels,
+		Source:   string(r.SrcEntityID),
+		Target:   string(r.TgtEntityID),
+		Aspects:  aspects,
+		Promoted: promotedLabels(obj, promoted),
+	}
+}
+
+// promotedLabels returns the values of the labels of the topo object
+// with the keys promoted, leaving out the ones it does not have.
+func promotedLabels(object topoapi.Object, promoted []string) map[string]string {
+	values := make(map[string]string, len(promoted))
+	for _, key := range promoted {
+		if value, ok := object.Labels[key]; ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// listAspects receives a list of topo Objects and store the aspects of
+// the types listed in opts according to the data structure of the
+// kpis.OnosTopoAspects KPI.
+// An aspect that can not be decoded is left out (and logged).
+func listAspects(objects []topoapi.Object, opts TopoOptions) kpis.KPI {
+	aspectsKPI := kpis.OnosTopoAspects()
+
+	for _, object := range objects {
+		for _, aspectType := range opts.Aspects {
+			aspect, ok := object.Aspects[aspectType]
+			if !ok || aspect == nil {
+				continue
+			}
+
+			fields, err := aspectFields(aspect.Value)
+			if err != nil {
+				log.Warnf("topo aspect %s of %s left out: %s", aspectType, object.ID, err)
+				continue
+			}
+
+			aspectsKPI.Aspects = append(aspectsKPI.Aspects, kpis.TopoAspect{
+				ObjectID:   string(object.ID),
+				ObjectType: strings.ToLower(object.Type.String()),
+				Type:       aspectType,
+				Fields:     fields,
+			})
+		}
 	}
+
+	return aspectsKPI
 }
 
-func listObjects(conn *grpc.ClientConn, filters *topoapi.Filters) ([]topoapi.Object, error) {
+// aspectFields decodes the JSON value of a topo aspect, returning its
+// scalar fields formatted as strings by field name. The fields with
+// nested objects or lists are left out.
+func aspectFields(value []byte) (map[string]string, error) {
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(decoded))
+	for field, v := range decoded {
+		switch v := v.(type) {
+		case string:
+			fields[field] = v
+		case float64:
+			fields[field] = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			fields[field] = strconv.FormatBool(v)
+		}
+	}
+	return fields, nil
+}
+
+func listObjects(ctx context.Context, conn *grpc.ClientConn, filters *topoapi.Filters) ([]topoapi.Object, error) {
 	client := topoapi.CreateTopoClient(conn)
 
-	resp, err := client.List(context.Background(), &topoapi.ListRequest{Filters: filters})
+	resp, err := client.List(ctx, &topoapi.ListRequest{Filters: filters})
 	if err != nil {
 		return nil, err
 	}
 	return resp.Objects, nil
 }
 
+// labelsAsCSV encodes the labels of the topo object as key=value,
+// sorted by key and separated by commas, so the encoding is stable.
 func labelsAsCSV(object topoapi.Object) string {
+	keys := make([]string, 0, len(object.Labels))
+	for k := range object.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	var buffer bytes.Buffer
-	first := true
-	for k, v := range object.Labels {
-		if !first {
+	for i, k := range keys {
+		if i > 0 {
 			buffer.WriteString(",")
 		}
 		buffer.WriteString(k)
 		buffer.WriteString("=")
-		buffer.WriteString(v)
-		first = false
+		buffer.WriteString(object.Labels[k])
 	}
 	return buffer.String()
 }
 
-func aspectsAsCSV(object topoapi.Object, verbose bool) string {
-	var buffer bytes.Buffer
-	first := true
-	if object.Aspects != nil {
-		for aspectType, aspect := range object.Aspects {
+// aspectsAsCSV encodes the aspect types of the topo object, sorted and
+// separated by commas.
+func aspectsAsCSV(object topoapi.Object) string {
+	aspectTypes := make([]string, 0, len(object.Aspects))
+	for aspectType := range object.Aspects {
+		aspectTypes = append(aspectTypes, aspectType)
+	}
+	sort.Strings(aspectTypes)
 
-			if !first {
-				buffer.WriteString(",")
-			}
-			buffer.WriteString(aspectType)
-			if verbose {
-				buffer.WriteString("=")
-				buffer.WriteString(bytes.NewBuffer(aspect.Value).String())
-			}
-			first = false
+	var buffer bytes.Buffer
+	for i, aspectType := range aspectTypes {
+		if i > 0 {
+			buffer.WriteString(",")
 		}
+		buffer.WriteString(aspectType)
 	}
 	return buffer.String()
 }
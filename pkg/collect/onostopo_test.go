@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"testing"
+
+	prototypes "github.com/gogo/protobuf/types"
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	exporterConfig "github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TopoObjectEncoding(t *testing.T) {
+	object := topoapi.Object{
+		ID:     "e2:1",
+		Type:   topoapi.Object_ENTITY,
+		Labels: map[string]string{"tac": "1", "plmnid": "138426", "cell": "a"},
+		Aspects: map[string]*prototypes.Any{
+			"onos.topo.Location": {Value: []byte(`{"lat":52.5,"lng":13.4,"ext":{"x":1}}`)},
+			"onos.topo.E2Node":   {Value: []byte(`{"serviceModels":{}}`)},
+			"onos.topo.Broken":   {Value: []byte(`{`)},
+		},
+	}
+
+	assert.Equal(t, "cell=a,plmnid=138426,tac=1", labelsAsCSV(object))
+	assert.Equal(t, "onos.topo.Broken,onos.topo.E2Node,onos.topo.Location", aspectsAsCSV(object))
+
+	entity := parseObjectEntity(object, []string{"plmnid", "nci"})
+	assert.Equal(t, map[string]string{"plmnid": "138426"}, entity.Promoted)
+
+	aspectsKPI := listAspects([]topoapi.Object{object}, TopoOptions{
+		Aspects: []string{"onos.topo.Location", "onos.topo.Broken", "onos.topo.Configurable"},
+	})
+	samples, err := aspectsKPI.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 1)
+
+	fields, err := aspectFields(object.Aspects["onos.topo.Location"].Value)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"lat": "52.5", "lng": "13.4"}, fields)
+}
+
+// exportedKPIs returns an Exported func of Options that exports only
+// the kpis named.
+func exportedKPIs(names ...string) func(kpi kpis.KPI) bool {
+	return func(kpi kpis.KPI) bool {
+		for _, name := range names {
+			if kpi.(kpis.LimitedKPI).Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func Test_TopoExports(t *testing.T) {
+	col := &onosTopoCollector{
+		collector: collector{exported: exportedKPIs("entities_count", "aspects")},
+	}
+	exports := col.topoExports()
+	assert.Equal(t, topoExports{entities: true}, exports)
+	assert.True(t, exports.entityObjects())
+	assert.False(t, exports.relationObjects())
+
+	col.topo.Aspects = []string{"onos.topo.Location"}
+	assert.True(t, col.topoExports().relationObjects())
+
+	entities := []topoapi.Object{{ID: "e2:1", Type: topoapi.Object_ENTITY}}
+	topoKPIs := col.appendEntityKPIs(nil, exports, entities)
+	topoKPIs = col.appendRelationKPIs(topoKPIs, exports, entities, nil)
+	assert.Len(t, topoKPIs, 2)
+
+	assert.Equal(t, topoExports{true, true, true, true, true, false}, (&onosTopoCollector{}).topoExports())
+}
+
+func Test_CollectNotExported(t *testing.T) {
+	// The collectors whose kpis are not exported do not connect to
+	// their service.
+	noTLS := true
+	for _, name := range []string{exporterConfig.ONOSXAPPKPIMON, exporterConfig.ONOSXAPPPCI, exporterConfig.ONOSE2T, exporterConfig.ONOSTOPO, exporterConfig.ONOSUENIB} {
+		col, err := CreateCollector(name, Options{
+			ServiceAddress: "localhost:1",
+			NoTLS:          &noTLS,
+			Exported:       exportedKPIs(),
+		})
+		assert.NoError(t, err, name)
+
+		collected, err := col.Collect(context.Background())
+		assert.NoError(t, err, name)
+		assert.Empty(t, collected, name)
+		assert.NoError(t, col.Close(), name)
+	}
+}
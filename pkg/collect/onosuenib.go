@@ -9,8 +9,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/onosproject/onos-api/go/onos/uenib"
 	"github.com/onosproject/onos-exporter/pkg/kpis"
@@ -19,77 +19,139 @@ import (
 
 // onosUenibCollector is the onos uenib collector.
 // It extracts all the uenib related kpis using the Collect method.
+// In watch mode the kpis are extracted from a table of the UEs kept
+// up to date with the uenib events, instead of listing them on every
+// Collect, along with the number of UEs added and removed.
 type onosUenibCollector struct {
 	collector
+	replica *uenibReplica
 }
 
 // Collect implements the Collector interface behavior for
 // onosUenibCollector, returning a list of kpis.KPI.
-func (col *onosUenibCollector) Collect() ([]kpis.KPI, error) {
+func (col *onosUenibCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exportsUEs := col.exports(kpis.OnosUenibUEs(), kpis.OnosUenibUECounts())
+
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("onosUenibCollector Collect missing service address")
 	}
 
-	conn, err := GetConnection(
-		col.config.getAddress(),
-		col.config.getCertPath(),
-		col.config.getKeyPath(),
-		col.config.noTLS(),
-	)
-	if err != nil {
-		return kpis, err
+	if col.replica != nil {
+		return col.collectReplica(ctx, exportsUEs, kpis)
+	}
+
+	return col.collectList(ctx, exportsUEs, kpis)
+}
+
+// collectList appends to colKPIs the UE kpis extracted from the UEs
+// listed from the onos uenib service, if exportsUEs.
+func (col *onosUenibCollector) collectList(ctx context.Context, exportsUEs bool, colKPIs []kpis.KPI) ([]kpis.KPI, error) {
+	if !exportsUEs {
+		return colKPIs, nil
 	}
-	defer conn.Close()
 
-	uenibKPI, err := listUEs(conn)
+	conn, err := col.conns.getConnection()
 	if err != nil {
-		return kpis, err
+		return colKPIs, err
+	}
+
+	// uenibKPIs keep the UEs received before an error (e.g., timeout),
+	// so they are returned as partial results.
+	uenibKPIs, err := listUEs(ctx, conn)
+	colKPIs = append(colKPIs, uenibKPIs...)
+
+	return colKPIs, err
+}
+
+// collectReplica appends to kpis the kpis extracted from the UE table
+// of the replica. If the replica is out of sync they are returned
+// along with the error, as partial results. The UE kpis are only
+// extracted if exportsUEs. Until the replica is synced for the first
+// time the UEs are listed instead, so a Collect does not wait for a
+// replica that may never be synced.
+func (col *onosUenibCollector) collectReplica(ctx context.Context, exportsUEs bool, colKPIs []kpis.KPI) ([]kpis.KPI, error) {
+	ues, added, removed, err := col.replica.list()
+	if ues == nil {
+		return col.collectList(ctx, exportsUEs, colKPIs)
+	}
+
+	changesKPI := kpis.OnosUenibUEChanges()
+	changesKPI.Added = added
+	changesKPI.Removed = removed
+
+	if exportsUEs {
+		colKPIs = append(colKPIs, ueKPIs(ues)...)
 	}
+	colKPIs = append(colKPIs, changesKPI)
 
-	kpis = append(kpis, uenibKPI)
+	return colKPIs, err
+}
 
-	return kpis, err
+// Close implements the Collector interface behavior for
+// onosUenibCollector, stopping its replica (if any).
+func (col *onosUenibCollector) Close() error {
+	if col.replica != nil {
+		col.replica.stop()
+	}
+	return col.collector.Close()
 }
 
 // listUEs receives a connection to a onos uenib service
 // to retrieve the uenib UEs Aspects and store them according to the
-// data structure of the kpis.OnosUenibUEs KPI.
-func listUEs(conn *grpc.ClientConn) (kpis.KPI, error) {
-	uenibKPI := kpis.OnosUenibUEs()
-	uenibKPI.UEs = make(map[string]kpis.UE)
+// data structure of the kpis.OnosUenibUEs KPI, see ueKPIs.
+// The UEs streamed until ctx is done are kept in the returned KPIs,
+// which are nil if the UEs could not be listed at all.
+func listUEs(ctx context.Context, conn *grpc.ClientConn) ([]kpis.KPI, error) {
+	ues := []uenib.UE{}
 
 	aspectTypes := []string{}
 
 	client := uenib.CreateUEServiceClient(conn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 	response, err := client.ListUEs(ctx, &uenib.ListUERequest{AspectTypes: aspectTypes})
 	if err != nil {
-
-		return uenibKPI, err
-	}
-
-	if err != nil {
-		return uenibKPI, err
+		return nil, err
 	}
 
 	for {
 		resp, err := response.Recv()
 		if err == io.EOF {
 			break
+		} else if err != nil && len(ues) == 0 {
+			return nil, err
 		} else if err != nil {
-			return uenibKPI, err
+			return ueKPIs(ues), err
 		} else {
-			ue := parseObjectUE(resp.UE, false)
-			uenibKPI.UEs[ue.ID] = ue
+			ues = append(ues, resp.UE)
+		}
+	}
 
+	return ueKPIs(ues), nil
+}
+
+// ueKPIs stores the UEs according to the data structure of the
+// kpis.OnosUenibUEs KPI, and of its aggregate kpis.OnosUenibUECounts
+// KPI, which counts each UE once by each of its aspect types.
+func ueKPIs(ues []uenib.UE) []kpis.KPI {
+	uenibKPI := kpis.OnosUenibUEs()
+	uenibKPI.UEs = make(map[string]kpis.UE)
+	countsKPI := kpis.OnosUenibUECounts()
+
+	for _, ue := range ues {
+		kpiUE := parseObjectUE(ue, false)
+		if _, ok := uenibKPI.UEs[kpiUE.ID]; !ok {
+			for aspectType := range ue.Aspects {
+				countsKPI.Add(aspectType)
+			}
 		}
+		uenibKPI.UEs[kpiUE.ID] = kpiUE
 	}
 
-	return uenibKPI, nil
+	return []kpis.KPI{uenibKPI, countsKPI}
 }
 
 func parseObjectUE(ue uenib.UE, verbose bool) kpis.UE {
@@ -105,6 +167,7 @@ func parseObjectUE(ue uenib.UE, verbose bool) kpis.UE {
 		}
 	}
 
+	sort.Strings(aspectsList)
 	aspects := strings.Join(aspectsList, ",")
 
 	return kpis.UE{
@@ -0,0 +1,191 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// defaultPollInterval is used by a Poller started without interval.
+const defaultPollInterval = 15 * time.Second
+
+// Snapshot holds the latest list of kpis.KPI successfully retrieved
+// by a Collector and the time of that retrieval (Timestamp).
+// Err stores the error of the latest retrieval attempt, so a Snapshot
+// with Err different from nil contains the KPIs of a previous attempt
+// (if any), which are stale, unless Partial is set: in that case
+// the KPIs are the partial results of the latest attempt.
+// Duration is the duration of the latest attempt and Errors counts
+// the failed attempts since the Poller was created.
+type Snapshot struct {
+	Collector string
+	KPIs      []kpis.KPI
+	Timestamp time.Time
+	Err       error
+	Partial   bool
+	Duration  time.Duration
+	Errors    uint64
+}
+
+// Stale returns for how long the KPIs of the Snapshot were retrieved
+// based on the time now. It returns false if the Snapshot never had
+// a successful retrieval.
+func (s Snapshot) Stale(now time.Time) (time.Duration, bool) {
+	if s.Timestamp.IsZero() {
+		return 0, false
+	}
+	return now.Sub(s.Timestamp), true
+}
+
+// Poller keeps the latest Snapshot of a Collector.
+// Its Collector can be polled on demand via Poll, or in the background
+// on its own interval after Start is called. This way the retrieval of
+// KPIs by an exporter can be decoupled from the calls to onos services.
+type Poller struct {
+	collector Collector
+	interval  time.Duration
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPoller creates a Poller of the Collector col, using the interval
+// to poll col in the background if started.
+func NewPoller(col Collector, interval time.Duration) *Poller {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	return &Poller{
+		collector: col,
+		interval:  interval,
+		snapshot: Snapshot{
+			Collector: col.Name(),
+		},
+	}
+}
+
+// Name returns the name of the Collector polled by Poller.
+func (p *Poller) Name() string {
+	return p.collector.Name()
+}
+
+// Poll calls the Collect method of the Collector with ctx, storing
+// and returning the resulting Snapshot.
+// If Collect fails returning some KPIs, they are stored as partial
+// results, otherwise the KPIs of the Snapshot are left as they were.
+func (p *Poller) Poll(ctx context.Context) Snapshot {
+	begin := time.Now()
+	colKPIs, err := p.collector.Collect(ctx)
+	duration := time.Since(begin)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.snapshot.Err = err
+	p.snapshot.Partial = false
+	p.snapshot.Duration = duration
+	if err != nil {
+		log.Errorf("collector %s Collect error: %s", p.Name(), err)
+		p.snapshot.Errors++
+	}
+
+	if err == nil || len(colKPIs) > 0 {
+		p.snapshot.KPIs = colKPIs
+		p.snapshot.Timestamp = time.Now()
+		p.snapshot.Partial = err != nil
+	}
+
+	return p.snapshot
+}
+
+// Snapshot returns the latest Snapshot stored by Poller.
+func (p *Poller) Snapshot() Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.snapshot
+}
+
+// Start polls the Collector in the background, immediately and then
+// once every interval, until Stop is called.
+func (p *Poller) Start() {
+	if p.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.Poll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops polling the Collector in the background, cancelling
+// an ongoing poll and waiting for it to finish.
+func (p *Poller) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+	p.cancel = nil
+}
+
+// Close stops the Poller and closes its Collector.
+func (p *Poller) Close() error {
+	p.Stop()
+	return p.collector.Close()
+}
+
+// Poll polls each Poller concurrently with ctx, returning their Snapshots.
+func Poll(ctx context.Context, pollers []*Poller) []Snapshot {
+	snapshots := make([]Snapshot, len(pollers))
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(pollers))
+
+	for i, p := range pollers {
+		go func(i int, p *Poller) {
+			snapshots[i] = p.Poll(ctx)
+			wg.Done()
+		}(i, p)
+	}
+	wg.Wait()
+
+	return snapshots
+}
+
+// Snapshots returns the latest Snapshot of each Poller.
+func Snapshots(pollers []*Poller) []Snapshot {
+	snapshots := make([]Snapshot, 0, len(pollers))
+
+	for _, p := range pollers {
+		snapshots = append(snapshots, p.Snapshot())
+	}
+
+	return snapshots
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCollector struct {
+	collector
+	err error
+}
+
+func (col *testCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	if col.err != nil {
+		return nil, col.err
+	}
+	return []kpis.KPI{kpis.OnosTopoEntities()}, nil
+}
+
+func Test_PollerSnapshot(t *testing.T) {
+	col := &testCollector{collector: collector{name: "test"}}
+	p := NewPoller(col, time.Hour)
+
+	_, ok := p.Snapshot().Stale(time.Now())
+	assert.False(t, ok)
+
+	snapshot := p.Poll(context.Background())
+	assert.NoError(t, snapshot.Err)
+	assert.Equal(t, "test", snapshot.Collector)
+	assert.Len(t, snapshot.KPIs, 1)
+
+	col.err = fmt.Errorf("unavailable")
+	stale := p.Poll(context.Background())
+	assert.Error(t, stale.Err)
+	assert.Len(t, stale.KPIs, 1)
+	assert.Equal(t, snapshot.Timestamp, stale.Timestamp)
+	assert.Equal(t, uint64(1), stale.Errors)
+}
+
+func Test_PollerStart(t *testing.T) {
+	col := &testCollector{collector: collector{name: "test"}}
+	p := NewPoller(col, time.Hour)
+
+	p.Start()
+	assert.Eventually(t, func() bool {
+		_, ok := p.Snapshot().Stale(time.Now())
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, p.Close())
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"sync"
+	"time"
+
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// e2Controls keeps the state of the E2 control relations and of the
+// mastership of the E2 nodes across collects: the time each CONTROLS
+// relation was first seen, and the last mastership term of each E2
+// node along with the number of mastership changes seen so far.
+// The relations and nodes that are gone are forgotten, so the state
+// kept does not grow with them.
+type e2Controls struct {
+	mu      sync.Mutex
+	seen    map[topoapi.ID]time.Time
+	terms   map[topoapi.ID]uint64
+	changes map[topoapi.ID]uint64
+}
+
+func newE2Controls() *e2Controls {
+	return &e2Controls{
+		seen:    make(map[topoapi.ID]time.Time),
+		terms:   make(map[topoapi.ID]uint64),
+		changes: make(map[topoapi.ID]uint64),
+	}
+}
+
+// list receives the topo entities and relations at time now and
+// stores the E2T instances, the CONTROLS relations, from E2T instances
+// to E2 nodes, and the MastershipState aspects of the E2 nodes
+// according to the data structure of the kpis.OnosTopoE2Controls KPI.
+// The mastership changes of a node are counted by the increase of its
+// term, so the changes made between two collects are not missed.
+func (c *e2Controls) list(now time.Time, entities []topoapi.Object, relations []topoapi.Object) kpis.KPI {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	controlsKPI := kpis.OnosTopoE2Controls()
+	controlsKPI.Controls = make(map[string]kpis.TopoE2Control)
+	controlsKPI.Nodes = make(map[string]kpis.TopoE2Mastership)
+
+	seen := make(map[topoapi.ID]time.Time)
+	for _, relation := range relations {
+		r := relation.GetRelation()
+		if r == nil || r.KindID != topoapi.CONTROLS {
+			continue
+		}
+		first, ok := c.seen[relation.ID]
+		if !ok {
+			first = now
+		}
+		seen[relation.ID] = first
+
+		controlsKPI.Controls[string(relation.ID)] = kpis.TopoE2Control{
+			RelationID: string(relation.ID),
+			E2TID:      string(r.SrcEntityID),
+			NodeID:     string(r.TgtEntityID),
+			Age:        now.Sub(first).Seconds(),
+		}
+	}
+	c.seen = seen
+
+	terms := make(map[topoapi.ID]uint64)
+	changes := make(map[topoapi.ID]uint64)
+	for i := range entities {
+		object := &entities[i]
+		if entity := object.GetEntity(); entity != nil && entity.KindID == topoapi.E2T {
+			controlsKPI.E2TIDs = append(controlsKPI.E2TIDs, string(object.ID))
+		}
+
+		mastership := &topoapi.MastershipState{}
+		if object.GetEntity() == nil || !getTopoAspect(object, mastership) {
+			continue
+		}
+
+		terms[object.ID] = mastership.Term
+		changes[object.ID] = c.changes[object.ID]
+		if last, ok := c.terms[object.ID]; ok && mastership.Term > last {
+			changes[object.ID] += mastership.Term - last
+		}
+
+		node := kpis.TopoE2Mastership{
+			NodeID:  string(object.ID),
+			Term:    mastership.Term,
+			Master:  mastership.NodeId,
+			Changes: changes[object.ID],
+		}
+		if control, ok := controlsKPI.Controls[mastership.NodeId]; ok && control.NodeID == node.NodeID {
+			node.HasMaster = true
+			control.Master = true
+			controlsKPI.Controls[mastership.NodeId] = control
+		}
+		controlsKPI.Nodes[node.NodeID] = node
+	}
+	c.terms = terms
+	c.changes = changes
+
+	return controlsKPI
+}
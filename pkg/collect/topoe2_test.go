@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"testing"
+	"time"
+
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func kpiValues(t *testing.T, kpi kpis.KPI) map[string]float64 {
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, sample := range samples {
+		values[sample.Name] += sample.Value
+	}
+	return values
+}
+
+func Test_E2Controls(t *testing.T) {
+	node := func(term uint64, master string) topoapi.Object {
+		object := topoapi.Object{
+			ID:   "e2:1",
+			Type: topoapi.Object_ENTITY,
+			Obj:  &topoapi.Object_Entity{Entity: &topoapi.Entity{KindID: topoapi.E2NODE}},
+		}
+		assert.NoError(t, object.SetAspect(&topoapi.MastershipState{Term: term, NodeId: master}))
+		return object
+	}
+	control := func(id, e2t string) topoapi.Object {
+		return topoapi.Object{
+			ID:   topoapi.ID(id),
+			Type: topoapi.Object_RELATION,
+			Obj:  &topoapi.Object_Relation{Relation: &topoapi.Relation{KindID: topoapi.CONTROLS, SrcEntityID: topoapi.ID(e2t), TgtEntityID: "e2:1"}},
+		}
+	}
+
+	e2t := func(id string) topoapi.Object {
+		return topoapi.Object{
+			ID:   topoapi.ID(id),
+			Type: topoapi.Object_ENTITY,
+			Obj:  &topoapi.Object_Entity{Entity: &topoapi.Entity{KindID: topoapi.E2T}},
+		}
+	}
+
+	controls := newE2Controls()
+	t1 := time.Unix(1630000000, 0)
+
+	values := kpiValues(t, controls.list(t1,
+		[]topoapi.Object{node(3, "e2t-1-controls-e2:1")},
+		[]topoapi.Object{control("e2t-1-controls-e2:1", "e2t-1"), control("e2t-2-controls-e2:1", "e2t-2")}))
+	assert.Equal(t, 2.0, values["onos_topo_e2t_connected_nodes"])
+	assert.Equal(t, 0.0, values["onos_topo_e2_connection_age_seconds"])
+	assert.Equal(t, 1.0, values["onos_topo_e2_connection_master"])
+	assert.Equal(t, 1.0, values["onos_topo_e2node_has_master"])
+	assert.Equal(t, 0.0, values["onos_topo_e2node_mastership_changes_total"])
+
+	// The mastership moved twice, to a relation that is gone. The node
+	// is counted once by e2t-1, whatever its number of relations, and
+	// e2t-2 has no nodes left.
+	controlsKPI := controls.list(t1.Add(10*time.Second),
+		[]topoapi.Object{node(5, "e2t-3-controls-e2:1"), e2t("e2t-1"), e2t("e2t-2")},
+		[]topoapi.Object{control("e2t-1-controls-e2:1", "e2t-1"), control("e2t-1-controls-e2:1-2", "e2t-1")})
+	samples, err := controlsKPI.Samples()
+	assert.NoError(t, err)
+	connected := map[string]float64{}
+	for _, sample := range samples {
+		if sample.Name == "onos_topo_e2t_connected_nodes" {
+			connected[sample.Labels[0].Value] = sample.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{"e2t-1": 1, "e2t-2": 0}, connected)
+
+	values = kpiValues(t, controlsKPI)
+	assert.Equal(t, 10.0, values["onos_topo_e2_connection_age_seconds"])
+	assert.Equal(t, 0.0, values["onos_topo_e2_connection_master"])
+	assert.Equal(t, 0.0, values["onos_topo_e2node_has_master"])
+	assert.Equal(t, 2.0, values["onos_topo_e2node_mastership_changes_total"])
+
+	// A relation gone and back is seen as new.
+	values = kpiValues(t, controls.list(t1.Add(20*time.Second),
+		[]topoapi.Object{node(5, "e2t-2-controls-e2:1")},
+		[]topoapi.Object{control("e2t-2-controls-e2:1", "e2t-2")}))
+	assert.Equal(t, 0.0, values["onos_topo_e2_connection_age_seconds"])
+	assert.Equal(t, 1.0, values["onos_topo_e2node_has_master"])
+	assert.Equal(t, 2.0, values["onos_topo_e2node_mastership_changes_total"])
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// listRAN receives the topo entities and relations and decodes the
+// known RAN aspects of the entities (E2Node, E2Cell, MastershipState
+// and Location) according to the data structure of the
+// kpis.OnosTopoRAN KPI. The cells of each E2 node are the targets of
+// its CONTAINS relations.
+// An aspect that can not be decoded is left out (and logged).
+func listRAN(entities []topoapi.Object, relations []topoapi.Object) kpis.KPI {
+	ranKPI := kpis.OnosTopoRAN()
+	ranKPI.Nodes = make(map[string]kpis.TopoE2Node)
+	ranKPI.Cells = make(map[string]kpis.TopoE2Cell)
+	ranKPI.Locations = make(map[string]kpis.TopoLocation)
+
+	for i := range entities {
+		object := &entities[i]
+
+		e2Node := &topoapi.E2Node{}
+		if getTopoAspect(object, e2Node) {
+			node := kpis.TopoE2Node{ID: string(object.ID)}
+			for _, sm := range e2Node.ServiceModels {
+				if sm == nil {
+					continue
+				}
+				node.ServiceModels = append(node.ServiceModels, kpis.TopoServiceModel{Name: sm.Name, OID: sm.OID})
+			}
+			sort.Slice(node.ServiceModels, func(i, j int) bool {
+				return node.ServiceModels[i].OID < node.ServiceModels[j].OID
+			})
+
+			mastership := &topoapi.MastershipState{}
+			if getTopoAspect(object, mastership) {
+				node.HasMastership = true
+				node.Term = mastership.Term
+				node.Master = mastership.NodeId
+			}
+			ranKPI.Nodes[node.ID] = node
+		}
+
+		e2Cell := &topoapi.E2Cell{}
+		if getTopoAspect(object, e2Cell) {
+			cell := kpis.TopoE2Cell{
+				ID:       string(object.ID),
+				CellType: e2Cell.CellType,
+				PCI:      e2Cell.PCI,
+				EARFCN:   e2Cell.EARFCN,
+			}
+			if e2Cell.CellGlobalID != nil {
+				cell.CellGlobalID = e2Cell.CellGlobalID.Value
+			}
+			ranKPI.Cells[cell.ID] = cell
+		}
+
+		location := &topoapi.Location{}
+		if getTopoAspect(object, location) {
+			ranKPI.Locations[string(object.ID)] = parseLocation(object, location)
+		}
+	}
+
+	for _, relation := range relations {
+		r := relation.GetRelation()
+		if r == nil || r.KindID != topoapi.CONTAINS {
+			continue
+		}
+		node, ok := ranKPI.Nodes[string(r.SrcEntityID)]
+		if !ok {
+			continue
+		}
+		cell, ok := ranKPI.Cells[string(r.TgtEntityID)]
+		if !ok {
+			continue
+		}
+		node.Cells++
+		ranKPI.Nodes[node.ID] = node
+		cell.NodeID = node.ID
+		ranKPI.Cells[cell.ID] = cell
+	}
+
+	return ranKPI
+}
+
+// getTopoAspect decodes into aspect the aspect of the topo object of
+// its type, reporting if the object has it. An aspect that can not be
+// decoded is logged, and reported as missing.
+func getTopoAspect(object *topoapi.Object, aspect proto.Message) bool {
+	aspectType := proto.MessageName(aspect)
+	if _, ok := object.Aspects[aspectType]; !ok {
+		return false
+	}
+	if err := object.GetAspect(aspect); err != nil {
+		log.Warnf("topo aspect %s of %s left out: %s", aspectType, object.ID, err)
+		return false
+	}
+	return true
+}
+
+// parseLocation returns the geolocation of the topo object from its
+// Location aspect, which is WGS-84, or else the deprecated lat and lng.
+func parseLocation(object *topoapi.Object, location *topoapi.Location) kpis.TopoLocation {
+	var kindID topoapi.ID
+	if e := object.GetEntity(); e != nil {
+		kindID = e.KindID
+	}
+
+	topoLocation := kpis.TopoLocation{
+		EntityID: string(object.ID),
+		Kind:     string(kindID),
+	}
+	if wgs84 := location.GetWgs84(); wgs84 != nil {
+		topoLocation.Latitude = wgs84.LatitudeDeg
+		topoLocation.Longitude = wgs84.LongitudeDeg
+		topoLocation.Altitude = wgs84.AltitudeM
+	} else {
+		topoLocation.Latitude = location.Lat
+		topoLocation.Longitude = location.Lng
+	}
+	return topoLocation
+}
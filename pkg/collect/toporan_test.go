@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"testing"
+
+	prototypes "github.com/gogo/protobuf/types"
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ListRAN(t *testing.T) {
+	node := topoapi.Object{
+		ID:   "e2:1",
+		Type: topoapi.Object_ENTITY,
+		Obj:  &topoapi.Object_Entity{Entity: &topoapi.Entity{KindID: topoapi.E2NODE}},
+	}
+	assert.NoError(t, node.SetAspect(&topoapi.E2Node{ServiceModels: map[string]*topoapi.ServiceModelInfo{
+		"1.3.6.1.4.1.53148.1.1.2.2": {OID: "1.3.6.1.4.1.53148.1.1.2.2", Name: "kpm"},
+		"1.3.6.1.4.1.53148.1.1.2.3": {OID: "1.3.6.1.4.1.53148.1.1.2.3", Name: "rc"},
+	}}))
+	assert.NoError(t, node.SetAspect(&topoapi.MastershipState{Term: 3, NodeId: "e2:1-controls-e2t"}))
+
+	cell := topoapi.Object{
+		ID:   "e2:1/c1",
+		Type: topoapi.Object_ENTITY,
+		Obj:  &topoapi.Object_Entity{Entity: &topoapi.Entity{KindID: topoapi.E2CELL}},
+	}
+	assert.NoError(t, cell.SetAspect(&topoapi.E2Cell{PCI: 42, EARFCN: 40000, CellGlobalID: &topoapi.CellGlobalID{Value: "13842601454c001"}}))
+	assert.NoError(t, cell.SetAspect(&topoapi.Location{Ext: &topoapi.Location_Wgs84{Wgs84: &topoapi.Wgs84Location{LatitudeDeg: 52.5, LongitudeDeg: 13.4}}}))
+
+	broken := topoapi.Object{
+		ID:      "e2:2",
+		Type:    topoapi.Object_ENTITY,
+		Aspects: map[string]*prototypes.Any{"onos.topo.E2Node": {TypeUrl: "onos.topo.E2Node", Value: []byte("{")}},
+	}
+
+	contains := topoapi.Object{
+		ID:   "e2:1-contains-e2:1/c1",
+		Type: topoapi.Object_RELATION,
+		Obj:  &topoapi.Object_Relation{Relation: &topoapi.Relation{KindID: topoapi.CONTAINS, SrcEntityID: "e2:1", TgtEntityID: "e2:1/c1"}},
+	}
+
+	ranKPI := listRAN([]topoapi.Object{node, cell, broken}, []topoapi.Object{contains})
+	samples, err := ranKPI.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 7)
+
+	values := kpiValues(t, ranKPI)
+	assert.Equal(t, 1.0, values["onos_topo_e2node_cells"])
+	assert.Equal(t, 2.0, values["onos_topo_e2node_service_model_info"])
+	assert.Equal(t, 3.0, values["onos_topo_e2node_mastership_term"])
+	assert.Equal(t, 42.0, values["onos_topo_e2cell_pci"])
+	assert.Equal(t, 40000.0, values["onos_topo_e2cell_earfcn"])
+	assert.Equal(t, 1.0, values["onos_topo_location_info"])
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+)
+
+// topoReplica keeps an in-memory replica of the topo entities and
+// relations of an onos topo service, kept up to date in the background
+// by a watcher with the events of the topo Watch API.
+// After a stream error the replica is synced again from scratch, in
+// the meantime it keeps serving its objects as stale.
+type topoReplica struct {
+	replica
+	conns   *connManager
+	watcher *watcher
+
+	objects map[topoapi.ID]topoapi.Object
+}
+
+func newTopoReplica(name string, conns *connManager) *topoReplica {
+	r := &topoReplica{
+		conns: conns,
+	}
+	r.init(name)
+	r.watcher = newWatcher(name, r.sync)
+	return r
+}
+
+// start starts syncing the replica in the background.
+func (r *topoReplica) start() {
+	r.watcher.start()
+}
+
+// stop stops syncing the replica.
+func (r *topoReplica) stop() {
+	r.watcher.stop()
+}
+
+// sync lists the topo objects into the replica and applies the events
+// of a topo watch to it, until the watch fails.
+// The watch is opened before listing, so the changes made while listing
+// are not missed. The events that are older than the listed objects are
+// left out based on the object revisions.
+func (r *topoReplica) sync(ctx context.Context) error {
+	err := r.watch(ctx)
+	r.markFailed(err)
+	return err
+}
+
+func (r *topoReplica) watch(ctx context.Context) error {
+	conn, err := r.conns.getConnection()
+	if err != nil {
+		return err
+	}
+	client := topoapi.CreateTopoClient(conn)
+
+	filters := &topoapi.Filters{
+		ObjectTypes: []topoapi.Object_Type{topoapi.Object_ENTITY, topoapi.Object_RELATION},
+	}
+
+	stream, err := client.Watch(ctx, &topoapi.WatchRequest{Filters: filters, Noreplay: true})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.List(ctx, &topoapi.ListRequest{Filters: filters})
+	if err != nil {
+		return err
+	}
+	r.reset(resp.Objects)
+	log.Infof("%s replica synced with %d objects", r.name, len(resp.Objects))
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("%s watch stream closed", r.name)
+		} else if err != nil {
+			return err
+		}
+		r.apply(resp.Event)
+	}
+}
+
+// reset replaces the objects of the replica, which is synced from then on.
+func (r *topoReplica) reset(objects []topoapi.Object) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.objects = make(map[topoapi.ID]topoapi.Object, len(objects))
+	for _, object := range objects {
+		r.objects[object.ID] = object
+	}
+
+	r.markSynced()
+}
+
+// apply applies a topo event to the replica, unless the replica has
+// a newer revision of its object.
+func (r *topoReplica) apply(event topoapi.Event) {
+	object := event.Object
+	if object.Type != topoapi.Object_ENTITY && object.Type != topoapi.Object_RELATION {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.objects[object.ID]; ok && current.Revision > object.Revision {
+		return
+	}
+
+	if event.Type == topoapi.EventType_REMOVED {
+		delete(r.objects, object.ID)
+	} else {
+		r.objects[object.ID] = object
+	}
+}
+
+// list returns the entities and relations of the replica, without
+// waiting for it to be synced.
+// An error is returned along with the objects if the replica is not
+// in sync, in that case the objects are stale, or nil if the replica
+// was never synced.
+func (r *topoReplica) list() ([]topoapi.Object, []topoapi.Object, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.objects == nil {
+		return nil, nil, r.syncErr()
+	}
+
+	entities := []topoapi.Object{}
+	relations := []topoapi.Object{}
+	for _, object := range r.objects {
+		if object.Type == topoapi.Object_ENTITY {
+			entities = append(entities, object)
+		} else {
+			relations = append(relations, object)
+		}
+	}
+
+	return entities, relations, r.syncErr()
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	topoapi "github.com/onosproject/onos-api/go/onos/topo"
+	exporterConfig "github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func topoObject(id string, objType topoapi.Object_Type, revision topoapi.Revision) topoapi.Object {
+	return topoapi.Object{ID: topoapi.ID(id), Type: objType, Revision: revision}
+}
+
+func Test_TopoReplica(t *testing.T) {
+	conns := newConnManager("onos-topo", NewConfig("onos-topo"))
+	assert.NoError(t, conns.Close())
+	r := newTopoReplica("onos-topo", conns)
+
+	entities, _, err := r.list()
+	assert.EqualError(t, err, "onos-topo replica not synced yet")
+	assert.Nil(t, entities)
+
+	r.reset([]topoapi.Object{
+		topoObject("e2:1", topoapi.Object_ENTITY, 5),
+		topoObject("e2:1/cell", topoapi.Object_ENTITY, 5),
+		topoObject("controls", topoapi.Object_RELATION, 5),
+	})
+
+	// Events older than the listed objects are left out.
+	r.apply(topoapi.Event{Type: topoapi.EventType_REMOVED, Object: topoObject("e2:1", topoapi.Object_ENTITY, 4)})
+	r.apply(topoapi.Event{Type: topoapi.EventType_REMOVED, Object: topoObject("e2:1/cell", topoapi.Object_ENTITY, 6)})
+	r.apply(topoapi.Event{Type: topoapi.EventType_ADDED, Object: topoObject("e2:2", topoapi.Object_ENTITY, 7)})
+	r.apply(topoapi.Event{Type: topoapi.EventType_ADDED, Object: topoObject("kind", topoapi.Object_KIND, 7)})
+
+	entities, relations, err := r.list()
+	assert.NoError(t, err)
+	assert.Len(t, entities, 2)
+	assert.Len(t, relations, 1)
+
+	assert.Error(t, r.sync(context.Background()))
+	entities, relations, err = r.list()
+	assert.Error(t, err)
+	assert.Len(t, entities, 2)
+	assert.Len(t, relations, 1)
+}
+
+func Test_CollectReplicaNotSynced(t *testing.T) {
+	// A Collect without deadline does not wait for a replica that is
+	// never synced, the objects are listed instead.
+	noTLS, watch := true, true
+	for _, name := range []string{exporterConfig.ONOSTOPO, exporterConfig.ONOSUENIB} {
+		col, err := CreateCollector(name, Options{
+			ServiceAddress: "localhost:1",
+			NoTLS:          &noTLS,
+			Watch:          &watch,
+		})
+		assert.NoError(t, err, name)
+
+		done := make(chan error)
+		go func() {
+			_, err := col.Collect(context.Background())
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			assert.Error(t, err, name)
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s Collect waits for the replica", name)
+		}
+		assert.NoError(t, col.Close(), name)
+	}
+}
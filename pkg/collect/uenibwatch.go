@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/onosproject/onos-api/go/onos/uenib"
+)
+
+// uenibReplica keeps an in-memory table of the UEs of an onos uenib
+// service, kept up to date in the background by a watcher with the
+// events of the uenib WatchUEs API.
+// It counts the UEs added to and removed from the table, including the
+// changes found when the table is synced again after a stream error.
+type uenibReplica struct {
+	replica
+	conns   *connManager
+	watcher *watcher
+
+	ues     map[uenib.ID]uenib.UE
+	added   uint64
+	removed uint64
+}
+
+func newUenibReplica(name string, conns *connManager) *uenibReplica {
+	r := &uenibReplica{
+		conns: conns,
+	}
+	r.init(name)
+	r.watcher = newWatcher(name, r.sync)
+	return r
+}
+
+// start starts syncing the replica in the background.
+func (r *uenibReplica) start() {
+	r.watcher.start()
+}
+
+// stop stops syncing the replica.
+func (r *uenibReplica) stop() {
+	r.watcher.stop()
+}
+
+// sync lists the UEs into the replica and applies the events of
+// a UE watch to it, until the watch fails.
+// The watch is opened before listing, so the changes made while
+// listing are not missed.
+func (r *uenibReplica) sync(ctx context.Context) error {
+	err := r.watch(ctx)
+	r.markFailed(err)
+	return err
+}
+
+func (r *uenibReplica) watch(ctx context.Context) error {
+	conn, err := r.conns.getConnection()
+	if err != nil {
+		return err
+	}
+	client := uenib.CreateUEServiceClient(conn)
+
+	stream, err := client.WatchUEs(ctx, &uenib.WatchUERequest{Noreplay: true})
+	if err != nil {
+		return err
+	}
+
+	list, err := client.ListUEs(ctx, &uenib.ListUERequest{})
+	if err != nil {
+		return err
+	}
+	ues := []uenib.UE{}
+	for {
+		resp, err := list.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		ues = append(ues, resp.UE)
+	}
+	r.reset(ues)
+	log.Infof("%s replica synced with %d UEs", r.name, len(ues))
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("%s watch stream closed", r.name)
+		} else if err != nil {
+			return err
+		}
+		r.apply(resp.Event)
+	}
+}
+
+// reset replaces the UEs of the replica, which is synced from then on.
+// The UEs added or removed since the previous table are counted.
+func (r *uenibReplica) reset(ues []uenib.UE) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	table := make(map[uenib.ID]uenib.UE, len(ues))
+	for _, ue := range ues {
+		table[ue.ID] = ue
+	}
+
+	if r.ues != nil {
+		for id := range table {
+			if _, ok := r.ues[id]; !ok {
+				r.added++
+			}
+		}
+		for id := range r.ues {
+			if _, ok := table[id]; !ok {
+				r.removed++
+			}
+		}
+	}
+
+	r.ues = table
+	r.markSynced()
+}
+
+// apply applies a UE event to the replica, counting the UEs that
+// are added to or removed from the table.
+func (r *uenibReplica) apply(event uenib.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ue := event.UE
+	_, ok := r.ues[ue.ID]
+
+	if event.Type == uenib.EventType_REMOVED {
+		if ok {
+			delete(r.ues, ue.ID)
+			r.removed++
+		}
+		return
+	}
+
+	if !ok {
+		r.added++
+	}
+	r.ues[ue.ID] = ue
+}
+
+// list returns the UEs of the replica and the number of UEs added and
+// removed so far, without waiting for the replica to be synced.
+// An error is returned along with the UEs if the replica is not in
+// sync, in that case the UEs are stale, or nil if the replica was
+// never synced.
+func (r *uenibReplica) list() ([]uenib.UE, uint64, uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.ues == nil {
+		return nil, r.added, r.removed, r.syncErr()
+	}
+
+	ues := make([]uenib.UE, 0, len(r.ues))
+	for _, ue := range r.ues {
+		ues = append(ues, ue)
+	}
+
+	return ues, r.added, r.removed, r.syncErr()
+}
@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onosproject/onos-api/go/onos/uenib"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UenibReplica(t *testing.T) {
+	r := newUenibReplica("onos-uenib", nil)
+
+	r.reset([]uenib.UE{{ID: "ue-1"}, {ID: "ue-2"}})
+	r.apply(uenib.Event{Type: uenib.EventType_ADDED, UE: uenib.UE{ID: "ue-3"}})
+	r.apply(uenib.Event{Type: uenib.EventType_UPDATED, UE: uenib.UE{ID: "ue-3"}})
+	r.apply(uenib.Event{Type: uenib.EventType_REMOVED, UE: uenib.UE{ID: "ue-1"}})
+	r.apply(uenib.Event{Type: uenib.EventType_REMOVED, UE: uenib.UE{ID: "ue-1"}})
+
+	ues, added, removed, err := r.list()
+	assert.NoError(t, err)
+	assert.Len(t, ues, 2)
+	assert.Equal(t, uint64(1), added)
+	assert.Equal(t, uint64(1), removed)
+
+	// The changes missed while out of sync are counted on the next sync.
+	r.markFailed(context.Canceled)
+	r.reset([]uenib.UE{{ID: "ue-3"}, {ID: "ue-4"}, {ID: "ue-5"}})
+
+	ues, added, removed, err = r.list()
+	assert.NoError(t, err)
+	assert.Len(t, ues, 3)
+	assert.Equal(t, uint64(3), added)
+	assert.Equal(t, uint64(2), removed)
+}
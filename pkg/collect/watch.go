@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backoff between the attempts of a watcher to sync again
+// after its stream fails.
+const (
+	watchMinBackoff = 1 * time.Second
+	watchMaxBackoff = 30 * time.Second
+)
+
+// watcher runs a sync function in the background, calling it again
+// with backoff each time it returns, until the watcher is stopped.
+// A sync function keeps a replica of the state of an onos service up
+// to date, e.g., by listing its state and then applying the events of
+// a stream, and only returns when the stream fails.
+type watcher struct {
+	name string
+	sync func(ctx context.Context) error
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newWatcher(name string, sync func(ctx context.Context) error) *watcher {
+	return &watcher{
+		name: name,
+		sync: sync,
+	}
+}
+
+// start runs the watcher in the background, if not running yet.
+func (w *watcher) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+
+	go w.run(ctx)
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	backoff := watchMinBackoff
+	for {
+		begin := time.Now()
+		err := w.sync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// A sync that lasted longer than the maximum backoff was
+		// healthy for a while, so it starts over from the minimum.
+		if time.Since(begin) > watchMaxBackoff {
+			backoff = watchMinBackoff
+		}
+		log.Warnf("%s watch failed, syncing again in %s: %s", w.name, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > watchMaxBackoff {
+			backoff = watchMaxBackoff
+		}
+	}
+}
+
+// stop stops the watcher, waiting for its sync function to return.
+func (w *watcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+	w.cancel = nil
+}
+
+// replica defines the sync state of a replica of the state of an onos
+// service, kept up to date by a watcher. A replica that is out of sync
+// keeps serving its stale state until it is synced again.
+type replica struct {
+	name string
+
+	mu     sync.RWMutex
+	synced bool
+	err    error
+}
+
+func (r *replica) init(name string) {
+	r.name = name
+}
+
+// markSynced marks the replica as in sync. It must be called with
+// r.mu held.
+func (r *replica) markSynced() {
+	r.synced = true
+	r.err = nil
+}
+
+// markFailed marks the replica as out of sync because of err.
+func (r *replica) markFailed(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.synced = false
+	r.err = err
+}
+
+// syncErr returns an error if the replica is out of sync, nil
+// otherwise. The latest sync error of the replica is wrapped, if any.
+// It must be called with r.mu held.
+func (r *replica) syncErr() error {
+	if r.synced {
+		return nil
+	}
+	if r.err == nil {
+		return fmt.Errorf("%s replica not synced yet", r.name)
+	}
+	return fmt.Errorf("%s replica out of sync: %v", r.name, r.err)
+}
@@ -7,7 +7,11 @@ package collect
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	prototypes "github.com/gogo/protobuf/types"
 
@@ -16,115 +20,340 @@ import (
 	"google.golang.org/grpc"
 )
 
+// Const definitions of the policies for KPM measurements without value.
+const (
+	// KPMNoValueSkip leaves out the measurements without value.
+	KPMNoValueSkip = "skip"
+	// KPMNoValueNaN exports the measurements without value as NaN.
+	KPMNoValueNaN = "nan"
+)
+
+const (
+	// defaultKPMTimestampUnit is the unit of the KPM measurement
+	// timestamps if none is set.
+	defaultKPMTimestampUnit = time.Second
+
+	// defaultKPMMaxUESeries caps the number of per-UE measurements
+	// exported if no cap is set.
+	defaultKPMMaxUESeries = 1000
+)
+
+// KPMOptions defines how the kpimon collector exports the KPM
+// measurements. NoValue is the policy for the measurements without
+// value, KPMNoValueSkip if not set. TimestampUnit is the unit of the
+// unix timestamps of the measurement records, defaultKPMTimestampUnit
+// if not set. Metrics overrides the metric name, HELP text and unit
+// of the measurements in the default table of kpis.KpimonMetrics.
+// UESliceKeys parses the UE and slice of the per-UE and per-slice
+// measurements from their keys, see parseKpmKey. kpimon has no UE or
+// slice context in its measurements yet, so this needs a kpimon that
+// adds it to the keys.
+// MaxUESeries caps the number of per-UE measurements exported,
+// defaultKPMMaxUESeries if zero, none if negative.
+type KPMOptions struct {
+	NoValue       string
+	TimestampUnit time.Duration
+	Metrics       []kpis.KpimonMetric
+	UESliceKeys   bool
+	MaxUESeries   int
+}
+
+// maxUESeries returns the cap of per-UE measurements exported,
+// negative if there is none.
+func (o KPMOptions) maxUESeries() int {
+	switch {
+	case o.MaxUESeries < 0:
+		return -1
+	case o.MaxUESeries == 0:
+		return defaultKPMMaxUESeries
+	default:
+		return o.MaxUESeries
+	}
+}
+
+// timestamp converts the unix timestamp of a measurement record to
+// a time.Time, zero if the record has no timestamp.
+func (o KPMOptions) timestamp(ts uint64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	unit := o.TimestampUnit
+	if unit <= 0 {
+		unit = defaultKPMTimestampUnit
+	}
+	return time.Unix(0, int64(ts)*int64(unit))
+}
+
 // xappKpimonCollector is the onos xapp kpm collector.
 // It extracts all the kpm related kpis using the Collect method.
+// It keeps the records it exported, so a record listed again by
+// kpimon is not exported twice.
 type xappKpimonCollector struct {
 	collector
+	kpm      KPMOptions
+	exported *kpmExported
+}
+
+// kpmExported keeps the records of the KPM measurements exported, by
+// their key in the Data of the kpis.XappKpiMon KPI, which includes
+// the timestamp of the record.
+type kpmExported struct {
+	mu      sync.Mutex
+	records map[string]bool
+}
+
+func newKpmExported() *kpmExported {
+	return &kpmExported{
+		records: make(map[string]bool),
+	}
+}
+
+// filter leaves out of data the records already exported, and keeps
+// the remaining ones as exported. The records without timestamp are
+// always kept, as they are exported at the time of the collect.
+// A record older than one already exported (e.g., a late E2
+// indication) is kept, so it is exported at its own timestamp.
+// The records missing from data are forgotten, so the records kept do
+// not grow with the records kpimon no longer lists.
+func (e *kpmExported) filter(data map[string]kpis.KpimonData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	records := make(map[string]bool, len(data))
+	for key, record := range data {
+		if record.Timestamp.IsZero() {
+			continue
+		}
+		if e.records[key] {
+			delete(data, key)
+		}
+		records[key] = true
+	}
+	e.records = records
 }
 
 // Collect implements the Collector interface behavior for
 // XappKpimonCollector, returning a list of kpis.KPI.
-func (col *xappKpimonCollector) Collect() ([]kpis.KPI, error) {
+func (col *xappKpimonCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exportsKPM := col.exports(kpis.XappKpiMon())
+
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("XappKpimonCollector Collect missing service address")
 	}
 
-	conn, err := GetConnection(
-		col.config.getAddress(),
-		col.config.getCertPath(),
-		col.config.getKeyPath(),
-		col.config.noTLS(),
-	)
-	if err != nil {
-		return kpis, err
+	if !exportsKPM {
+		return kpis, nil
 	}
-	defer conn.Close()
 
-	kpmKPI, err := listKpmMetrics(conn)
+	conn, err := col.conns.getConnection()
 	if err != nil {
 		return kpis, err
 	}
 
-	kpis = append(kpis, kpmKPI)
+	// kpmKPI keeps the measurements of the well-formed keys, so they
+	// are returned as partial results along with a malformed key error.
+	kpmKPI, err := listKpmMetrics(ctx, conn, col.kpm, col.exported)
+	if kpmKPI != nil {
+		kpis = append(kpis, kpmKPI)
+	}
 
 	return kpis, err
+}
+
+// kpmKey defines the cell a KPM measurement key refers to, and the UE
+// and slice it refers to, if any.
+type kpmKey struct {
+	E2ID         string
+	NodeID       string
+	CellID       string
+	CellGlobalID string
+	UEID         string
+	SliceID      string
+}
+
+// parseKpmKey parses a KPM measurement key in the form
+// <e2 id>:<node id>:<cell id>:<cell global id>. With ueSliceKeys, it
+// can be followed by the segments :ue=<ue id> and/or :slice=<slice id>
+// for the per-UE and per-slice measurements, which kpimon does not
+// report yet. The other fields following the cell global id are
+// ignored.
+func parseKpmKey(key string, ueSliceKeys bool) (kpmKey, error) {
+	ids := strings.Split(key, ":")
+	if len(ids) < 4 {
+		return kpmKey{}, fmt.Errorf("malformed kpm key %q: expected at least 4 fields separated by ':', got %d", key, len(ids))
+	}
+
+	parsed := kpmKey{
+		E2ID:         ids[0],
+		NodeID:       ids[1],
+		CellID:       ids[2],
+		CellGlobalID: ids[3],
+	}
+
+	if !ueSliceKeys {
+		return parsed, nil
+	}
+	for _, segment := range ids[4:] {
+		name := strings.SplitN(segment, "=", 2)
+		if len(name) != 2 || name[1] == "" {
+			continue
+		}
+		switch {
+		case name[0] == "ue" && parsed.UEID == "":
+			parsed.UEID = name[1]
+		case name[0] == "slice" && parsed.SliceID == "":
+			parsed.SliceID = name[1]
+		}
+	}
+
+	return parsed, nil
+}
+
+// capUESeries leaves out of data the records of the per-UE
+// measurements beyond the first max ones, in order of their series,
+// returning how many measurements were left out. There is no cap if
+// max is negative.
+func capUESeries(data map[string]kpis.KpimonData, max int) int {
+	if max < 0 {
+		return 0
+	}
+	ueSeries := make(map[string][]string)
+	for key, record := range data {
+		if record.UEID != "" {
+			series := kpmSeriesKey(record)
+			ueSeries[series] = append(ueSeries[series], key)
+		}
+	}
+	if len(ueSeries) <= max {
+		return 0
+	}
+
+	series := make([]string, 0, len(ueSeries))
+	for s := range ueSeries {
+		series = append(series, s)
+	}
+	sort.Strings(series)
+	for _, s := range series[max:] {
+		for _, key := range ueSeries[s] {
+			delete(data, key)
+		}
+	}
+	return len(series) - max
+}
 
+// kpmSeriesKey returns the key of the series of a KPM measurement
+// record, shared by its records of every timestamp.
+func kpmSeriesKey(record kpis.KpimonData) string {
+	return strings.Join([]string{record.E2ID, record.NodeID, record.CellID, record.CellGlobalID, record.UEID, record.SliceID, record.MetricType}, "\xff")
 }
 
 // listKpmMetrics receives a connection to a kpm xapp service
 // to retrieve the kpm metrics and store them according to the
 // data structure of the kpis.XappKpiMon KPI.
-func listKpmMetrics(conn *grpc.ClientConn) (kpis.KPI, error) {
+// A measurement record whose value can not be decoded is left out
+// (and logged), so it does not drop the other records.
+// Every record of a measurement is kept with its own timestamp, unless
+// it was already exported according to exported.
+// The measurements of a malformed key are left out, and reported in
+// the error returned along with the KPI, which is nil if the
+// measurements could not be listed at all.
+func listKpmMetrics(ctx context.Context, conn *grpc.ClientConn, opts KPMOptions, exported *kpmExported) (kpis.KPI, error) {
 	xappKpiMonKPI := kpis.XappKpiMon()
 	xappKpiMonKPI.Data = make(map[string]kpis.KpimonData)
+	xappKpiMonKPI.Metrics = kpis.KpimonMetrics(opts.Metrics)
 
 	request := kpimonapi.GetRequest{}
 	client := kpimonapi.NewKpimonClient(conn)
 
-	respGetMeasurement, err := client.ListMeasurements(context.Background(), &request)
+	respGetMeasurement, err := client.ListMeasurements(ctx, &request)
 	if err != nil {
-		return xappKpiMonKPI, err
+		return nil, err
 	}
 
+	malformed := []string{}
 	for key, measItems := range respGetMeasurement.GetMeasurements() {
+		kpmKey, err := parseKpmKey(key, opts.UESliceKeys)
+		if err != nil {
+			malformed = append(malformed, err.Error())
+			continue
+		}
+
 		for _, measItem := range measItems.MeasurementItems {
 			for _, measRecord := range measItem.MeasurementRecords {
-				// timeStamp := measRecord.Timestamp
+				timestamp := opts.timestamp(measRecord.Timestamp)
 				measName := measRecord.MeasurementName
-				measValue := measRecord.MeasurementValue
-
-				ids := strings.Split(key, ":")
-				E2ID, NodeID, CellID, CellGlobalID := ids[0], ids[1], ids[2], ids[3]
-
-				// tmpCellID, err := strconv.Atoi(ids[1])
-				// if err != nil {
-				// 	return xappKpiMonKPI, err
-				// }
-
-				var value interface{}
-
-				switch {
-				case prototypes.Is(measValue, &kpimonapi.IntegerValue{}):
-					v := kpimonapi.IntegerValue{}
-					err := prototypes.UnmarshalAny(measValue, &v)
-					if err != nil {
-						log.Warn(err)
-					}
-					value = v.GetValue()
-
-				case prototypes.Is(measValue, &kpimonapi.RealValue{}):
-					v := kpimonapi.RealValue{}
-					err := prototypes.UnmarshalAny(measValue, &v)
-					if err != nil {
-						log.Warn(err)
-					}
-					value = v.GetValue()
-
-				case prototypes.Is(measValue, &kpimonapi.NoValue{}):
-					v := kpimonapi.NoValue{}
-					err := prototypes.UnmarshalAny(measValue, &v)
-					if err != nil {
-						log.Warn(err)
-					}
-					value = v.GetValue()
 
+				value, kind, err := kpmValue(measRecord.MeasurementValue)
+				if err != nil {
+					log.Warnf("kpm measurement %s of %s left out: %s", measName, key, err)
+					continue
+				}
+				if kind == kpis.KpimonNoValue && opts.NoValue != KPMNoValueNaN {
+					continue
 				}
 
-				uKey := fmt.Sprintf("%s:%s", key, measName)
+				uKey := fmt.Sprintf("%s:%s@%d", key, measName, measRecord.Timestamp)
 				xappKpiMonKPI.Data[uKey] = kpis.KpimonData{
-					// CellID:     fmt.Sprintf("%x", tmpCellID),
-					CellID:       CellID,
-					E2ID:         E2ID,
-					NodeID:       NodeID,
-					CellGlobalID: CellGlobalID,
+					CellID:       kpmKey.CellID,
+					E2ID:         kpmKey.E2ID,
+					NodeID:       kpmKey.NodeID,
+					CellGlobalID: kpmKey.CellGlobalID,
+					UEID:         kpmKey.UEID,
+					SliceID:      kpmKey.SliceID,
 					MetricType:   measName,
-					Value:        fmt.Sprintf("%v", value),
+					Value:        value,
+					Kind:         kind,
+					Timestamp:    timestamp,
 				}
 			}
 		}
 	}
 
+	if dropped := capUESeries(xappKpiMonKPI.Data, opts.maxUESeries()); dropped > 0 {
+		log.Warnf("%d per-UE kpm measurements left out, over the cap of %d", dropped, opts.maxUESeries())
+	}
+
+	if exported != nil {
+		exported.filter(xappKpiMonKPI.Data)
+	}
+
+	if len(malformed) > 0 {
+		sort.Strings(malformed)
+		return xappKpiMonKPI, fmt.Errorf("%d kpm keys left out: %s", len(malformed), strings.Join(malformed, "; "))
+	}
 	return xappKpiMonKPI, nil
 }
+
+// kpmValue decodes the value of a KPM measurement record, returning
+// it as a float64 along with its kind. A record without value is
+// returned as NaN.
+func kpmValue(measValue *prototypes.Any) (float64, kpis.KpimonValueKind, error) {
+	switch {
+	case prototypes.Is(measValue, &kpimonapi.IntegerValue{}):
+		v := kpimonapi.IntegerValue{}
+		if err := prototypes.UnmarshalAny(measValue, &v); err != nil {
+			return 0, "", err
+		}
+		return float64(v.GetValue()), kpis.KpimonIntegerValue, nil
+
+	case prototypes.Is(measValue, &kpimonapi.RealValue{}):
+		v := kpimonapi.RealValue{}
+		if err := prototypes.UnmarshalAny(measValue, &v); err != nil {
+			return 0, "", err
+		}
+		return v.GetValue(), kpis.KpimonRealValue, nil
+
+	case prototypes.Is(measValue, &kpimonapi.NoValue{}):
+		return math.NaN(), kpis.KpimonNoValue, nil
+
+	case measValue == nil:
+		return 0, "", fmt.Errorf("missing value")
+
+	default:
+		return 0, "", fmt.Errorf("unknown value type %s", measValue.GetTypeUrl())
+	}
+}
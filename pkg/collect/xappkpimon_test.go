@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	prototypes "github.com/gogo/protobuf/types"
+	kpimonapi "github.com/onosproject/onos-api/go/onos/kpimon"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KpmValue(t *testing.T) {
+	integer, err := prototypes.MarshalAny(&kpimonapi.IntegerValue{Value: 42})
+	assert.NoError(t, err)
+	realValue, err := prototypes.MarshalAny(&kpimonapi.RealValue{Value: 0.75})
+	assert.NoError(t, err)
+	noValue, err := prototypes.MarshalAny(&kpimonapi.NoValue{})
+	assert.NoError(t, err)
+
+	value, kind, err := kpmValue(integer)
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, value)
+	assert.Equal(t, kpis.KpimonIntegerValue, kind)
+
+	value, kind, err = kpmValue(realValue)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.75, value)
+	assert.Equal(t, kpis.KpimonRealValue, kind)
+
+	value, kind, err = kpmValue(noValue)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(value))
+	assert.Equal(t, kpis.KpimonNoValue, kind)
+
+	_, _, err = kpmValue(&prototypes.Any{TypeUrl: "type.googleapis.com/unknown"})
+	assert.Error(t, err)
+	_, _, err = kpmValue(nil)
+	assert.Error(t, err)
+}
+
+func Test_KpmTimestamps(t *testing.T) {
+	assert.True(t, KPMOptions{}.timestamp(0).IsZero())
+	assert.Equal(t, time.Unix(1630000000, 0), KPMOptions{}.timestamp(1630000000))
+	assert.Equal(t, time.Unix(1630000000, 5e8), KPMOptions{TimestampUnit: time.Millisecond}.timestamp(1630000000500))
+
+	exported := newKpmExported()
+	t1 := time.Unix(1630000000, 0)
+	t2 := t1.Add(time.Second)
+
+	data := map[string]kpis.KpimonData{
+		"e2:1:RRC.Conn.Avg@1630000001": {Timestamp: t2},
+		"e2:1:RRC.Conn.Max@0":          {},
+	}
+	exported.filter(data)
+	assert.Len(t, data, 2)
+
+	// A record listed again is not exported twice, and a late record,
+	// older than the one exported, is exported at its own timestamp.
+	data = map[string]kpis.KpimonData{
+		"e2:1:RRC.Conn.Avg@1630000000": {Timestamp: t1},
+		"e2:1:RRC.Conn.Avg@1630000001": {Timestamp: t2},
+		"e2:1:RRC.Conn.Max@0":          {},
+	}
+	exported.filter(data)
+	assert.Len(t, data, 2)
+	assert.Contains(t, data, "e2:1:RRC.Conn.Avg@1630000000")
+	assert.Contains(t, data, "e2:1:RRC.Conn.Max@0")
+
+	data = map[string]kpis.KpimonData{
+		"e2:1:RRC.Conn.Avg@1630000000": {Timestamp: t1},
+	}
+	exported.filter(data)
+	assert.Empty(t, data)
+
+	// The records no longer listed are forgotten.
+	data = map[string]kpis.KpimonData{
+		"e2:1:RRC.Conn.Avg@1630000001": {Timestamp: t2},
+	}
+	exported.filter(data)
+	assert.Len(t, data, 1)
+}
+
+func Test_ParseKpmKey(t *testing.T) {
+	key, err := parseKpmKey("e2:1/5153:1:13842601454c001", true)
+	assert.NoError(t, err)
+	assert.Equal(t, kpmKey{E2ID: "e2", NodeID: "1/5153", CellID: "1", CellGlobalID: "13842601454c001"}, key)
+
+	// The unknown fields following the cell global id are ignored.
+	for _, extra := range []string{"e2:1/5153:1:13842601454c001:0x1", "e2:1/5153:1:13842601454c001:ue=:qos=9"} {
+		key, err = parseKpmKey(extra, true)
+		assert.NoError(t, err, extra)
+		assert.Equal(t, kpmKey{E2ID: "e2", NodeID: "1/5153", CellID: "1", CellGlobalID: "13842601454c001"}, key, extra)
+	}
+
+	key, err = parseKpmKey("e2:1/5153:1:13842601454c001:slice=2:ue=imsi-1", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "imsi-1", key.UEID)
+	assert.Equal(t, "2", key.SliceID)
+
+	// The UE and slice segments are ignored without ueSliceKeys.
+	key, err = parseKpmKey("e2:1/5153:1:13842601454c001:slice=2:ue=imsi-1", false)
+	assert.NoError(t, err)
+	assert.Equal(t, kpmKey{E2ID: "e2", NodeID: "1/5153", CellID: "1", CellGlobalID: "13842601454c001"}, key)
+
+	for _, malformed := range []string{"", "e2", "e2:1:1"} {
+		_, err = parseKpmKey(malformed, true)
+		assert.Error(t, err, malformed)
+	}
+}
+
+func Test_CapUESeries(t *testing.T) {
+	data := map[string]kpis.KpimonData{
+		"e2:1:1:1:RRC.Conn.Avg@0":             {},
+		"e2:1:1:1:slice=1:DRB.UEThpDl@0":      {SliceID: "1"},
+		"e2:1:1:1:ue=3:DRB.UEThpDl@0":         {UEID: "3"},
+		"e2:1:1:1:ue=1:DRB.UEThpDl@1":         {UEID: "1", Timestamp: time.Unix(1, 0)},
+		"e2:1:1:1:ue=1:DRB.UEThpDl@2":         {UEID: "1", Timestamp: time.Unix(2, 0)},
+		"e2:1:1:1:slice=1:ue=2:DRB.UEThpDl@0": {UEID: "2", SliceID: "1"},
+	}
+
+	assert.Equal(t, 0, capUESeries(data, 3))
+	assert.Equal(t, 1, capUESeries(data, 2))
+	assert.Len(t, data, 5)
+	assert.NotContains(t, data, "e2:1:1:1:ue=3:DRB.UEThpDl@0")
+	assert.Equal(t, 0, capUESeries(data, KPMOptions{MaxUESeries: -1}.maxUESeries()))
+	assert.Len(t, data, 5)
+	assert.Equal(t, 2, capUESeries(data, 0))
+	assert.Len(t, data, 2)
+}
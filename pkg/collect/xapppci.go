@@ -5,72 +5,96 @@
 package collect
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	pciapi "github.com/onosproject/onos-api/go/onos/pci"
 	"github.com/onosproject/onos-exporter/pkg/kpis"
-	"google.golang.org/grpc"
 )
 
 // xappPciCollector is the onos xapp pci collector.
 // It extracts all the pci related kpis using the Collect method.
+// It keeps the history of the cells between collects, to export the
+// conflicts detected and resolved and the PCI changes as counters.
 type xappPciCollector struct {
 	collector
+	history *pciHistory
 }
 
 // Collect implements the Collector interface behavior for
 // XappPciCollector, returning a list of kpis.KPI.
-func (col *xappPciCollector) Collect() ([]kpis.KPI, error) {
+// The conflicts, resolutions and cells of the xapp are only listed if
+// a kpi exported needs them.
+func (col *xappPciCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	exportsInfo := col.exports(kpis.XappPciNumConflicts())
+	exportsResolved := col.exports(kpis.XappPciResolvedConflicts())
+	exportsHistory := col.exports(kpis.XappPciHistory())
+	exportsNeighbors := col.exports(kpis.XappPciNeighbors())
+
 	kpis := []kpis.KPI{}
+	ctx, cancel := col.withTimeout(ctx)
+	defer cancel()
 
 	if len(col.config.getAddress()) == 0 {
 		return kpis, fmt.Errorf("XappPciCollector Collect missing service address")
 	}
 
-	conn, err := GetConnection(
-		col.config.getAddress(),
-		col.config.getCertPath(),
-		col.config.getKeyPath(),
-		col.config.noTLS(),
-	)
+	conn, err := col.conns.getConnection()
 	if err != nil {
 		return kpis, err
 	}
-	defer conn.Close()
 
-	cellInfoKPI, err := listCellInfo(conn)
-	if err != nil {
-		return kpis, err
+	client := pciapi.NewPciClient(conn)
+
+	var conflictCells []*pciapi.PciCell
+	if exportsInfo || exportsHistory {
+		conflicts, err := client.GetConflicts(ctx, &pciapi.GetConflictsRequest{})
+		if err != nil {
+			return kpis, err
+		}
+		conflictCells = conflicts.GetCells()
+
+		if exportsInfo {
+			kpis = append(kpis, listCellInfo(conflictCells))
+		}
 	}
 
-	conflictsKPI, err := listResolvedConflictsAll(conn)
-	if err != nil {
-		return kpis, err
+	if exportsResolved || exportsHistory {
+		resolutions, err := client.GetResolvedConflicts(ctx, &pciapi.GetResolvedConflictsRequest{})
+		if err != nil {
+			return kpis, err
+		}
+
+		if exportsResolved {
+			kpis = append(kpis, listResolvedConflictsAll(resolutions.GetCells()))
+		}
+		if exportsHistory {
+			kpis = append(kpis, col.history.update(time.Now(), conflictCells, resolutions.GetCells()))
+		}
 	}
 
-	kpis = append(kpis, cellInfoKPI)
-	kpis = append(kpis, conflictsKPI)
+	if exportsNeighbors {
+		cells, err := client.GetCells(ctx, &pciapi.GetCellsRequest{})
+		if err != nil {
+			return kpis, err
+		}
+
+		kpis = append(kpis, listNeighbors(cells.GetCells()))
+	}
 
-	return kpis, err
+	return kpis, nil
 }
 
-// listCellInfo receives a connection to a pci xapp service
-// to retrieve the pci conflicts and store them according to the
-// data structure of the kpis.XappPciNumConflicts KPI.
-func listCellInfo(conn *grpc.ClientConn) (kpis.KPI, error) {
+// listCellInfo receives the cells in conflict of a pci xapp service
+// and stores them according to the data structure of the
+// kpis.XappPciNumConflicts KPI.
+func listCellInfo(cells []*pciapi.PciCell) kpis.KPI {
 	numConflictsKPI := kpis.XappPciNumConflicts()
 	numConflictsKPI.Cells = make(map[string]kpis.CellInfo)
 
-	request := pciapi.GetConflictsRequest{}
-	client := pciapi.NewPciClient(conn)
-	response, err := client.GetConflicts(context.Background(), &request)
-	if err != nil {
-		return numConflictsKPI, err
-	}
-
-	for _, cell := range response.GetCells() {
+	for _, cell := range cells {
 
 		cellID := fmt.Sprintf("%x", cell.Id)
 		nodeID := cell.NodeId
@@ -79,50 +103,63 @@ func listCellInfo(conn *grpc.ClientConn) (kpis.KPI, error) {
 
 		cellDlearfcn := float64(cell.Dlearfcn)
 
-		neighbors := neighborsAsCSV(cell)
-
 		cInfo := kpis.CellInfo{
-			CellID:        cellID,
-			NodeID:        nodeID,
-			CellType:      cellType,
-			CellPci:       cellPci,
-			CellDlearfcn:  cellDlearfcn,
-			CellNeighbors: neighbors,
+			CellID:       cellID,
+			NodeID:       nodeID,
+			CellType:     cellType,
+			CellPci:      cellPci,
+			CellDlearfcn: cellDlearfcn,
 		}
 		numConflictsKPI.Cells[cellID] = cInfo
 	}
 
-	return numConflictsKPI, nil
+	return numConflictsKPI
 }
 
-func neighborsAsCSV(cell *pciapi.PciCell) string {
-	var buffer bytes.Buffer
-	first := true
-	for _, neighbor := range cell.NeighborIds {
-		if !first {
-			buffer.WriteString(",")
+// listNeighbors receives the cells of a pci xapp service and stores
+// their directed neighbor relations according to the data structure
+// of the kpis.XappPciNeighbors KPI. Each relation tells if the cell
+// and its neighbor have the same PCI and the same DL EARFCN, unknown
+// if the neighbor is not a cell of the service.
+func listNeighbors(cells []*pciapi.PciCell) kpis.KPI {
+	neighborsKPI := kpis.XappPciNeighbors()
+	neighborsKPI.Cells = make(map[string][]kpis.CellNeighbor)
+
+	byID := make(map[uint64]*pciapi.PciCell, len(cells))
+	for _, cell := range cells {
+		byID[cell.Id] = cell
+	}
+
+	for _, cell := range cells {
+		cellID := fmt.Sprintf("%x", cell.Id)
+		neighbors := []kpis.CellNeighbor{}
+		for _, neighborID := range cell.NeighborIds {
+			neighbor := kpis.CellNeighbor{
+				CellID:     cellID,
+				NeighborID: fmt.Sprintf("%x", neighborID),
+				SamePci:    kpis.NeighborUnknown,
+				SameEarfcn: kpis.NeighborUnknown,
+			}
+			if n, ok := byID[neighborID]; ok {
+				neighbor.SamePci = strconv.FormatBool(n.Pci == cell.Pci)
+				neighbor.SameEarfcn = strconv.FormatBool(n.Dlearfcn == cell.Dlearfcn)
+			}
+			neighbors = append(neighbors, neighbor)
 		}
-		buffer.WriteString(fmt.Sprintf("%x", neighbor))
-		first = false
+		neighborsKPI.Cells[cellID] = neighbors
 	}
-	return buffer.String()
+
+	return neighborsKPI
 }
 
-// listNumConflictsAll receives a connection to a pci xapp service
-// to retrieve the pci conflicts and store them according to the
-// data structure of the kpis.XappPciNumConflicts KPI.
-func listResolvedConflictsAll(conn *grpc.ClientConn) (kpis.KPI, error) {
+// listResolvedConflictsAll receives the cell resolutions of a pci
+// xapp service and stores them according to the data structure of
+// the kpis.XappPciResolvedConflicts KPI.
+func listResolvedConflictsAll(cells []*pciapi.CellResolution) kpis.KPI {
 	resolvedConflictsKPI := kpis.XappPciResolvedConflicts()
 	resolvedConflictsKPI.Cells = make(map[string]kpis.CellConflict)
 
-	request := pciapi.GetResolvedConflictsRequest{}
-	client := pciapi.NewPciClient(conn)
-	response, err := client.GetResolvedConflicts(context.Background(), &request)
-	if err != nil {
-		return resolvedConflictsKPI, err
-	}
-
-	for _, cell := range response.GetCells() {
+	for _, cell := range cells {
 		cellID := fmt.Sprintf("%x", cell.Id)
 
 		cInfo := kpis.CellConflict{
@@ -134,5 +171,5 @@ func listResolvedConflictsAll(conn *grpc.ClientConn) (kpis.KPI, error) {
 		resolvedConflictsKPI.Cells[cellID] = cInfo
 	}
 
-	return resolvedConflictsKPI, nil
+	return resolvedConflictsKPI
 }
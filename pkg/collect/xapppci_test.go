@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"testing"
+	"time"
+
+	pciapi "github.com/onosproject/onos-api/go/onos/pci"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PciHistory(t *testing.T) {
+	history := newPciHistory()
+	t1 := time.Unix(1630000000, 0)
+
+	values := kpiValues(t, history.update(t1,
+		[]*pciapi.PciCell{{Id: 1}, {Id: 2}},
+		[]*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 3}}))
+	assert.Equal(t, 2.0, values["onos_xapppci_conflicts_detected_total"])
+	assert.Equal(t, 0.0, values["onos_xapppci_conflicts_resolved_total"])
+	assert.Equal(t, 0.0, values["onos_xapppci_pci_changes_total"])
+	assert.Equal(t, 2.0, values["onos_xapppci_unresolved_conflicts"])
+
+	// Cell 1 is still in conflict and cell 2 got its first PCI, its
+	// resolved conflicts being the baseline of the ones counted.
+	values = kpiValues(t, history.update(t1.Add(10*time.Second),
+		[]*pciapi.PciCell{{Id: 1}},
+		[]*pciapi.CellResolution{{Id: 1, ResolvedPci: 11, ResolvedConflicts: 4}, {Id: 2, ResolvedPci: 20, ResolvedConflicts: 1}}))
+	assert.Equal(t, 2.0, values["onos_xapppci_conflicts_detected_total"])
+	assert.Equal(t, 1.0, values["onos_xapppci_conflicts_resolved_total"])
+	assert.Equal(t, 1.0, values["onos_xapppci_pci_changes_total"])
+	assert.Equal(t, 10.0, values["onos_xapppci_pci_last_change_age_seconds"])
+	assert.Equal(t, 1.0, values["onos_xapppci_unresolved_conflicts"])
+
+	// Cell 2 is in conflict again and the resolved conflicts of cell 1
+	// are reset, e.g., the xapp restarted.
+	values = kpiValues(t, history.update(t1.Add(20*time.Second),
+		[]*pciapi.PciCell{{Id: 2}},
+		[]*pciapi.CellResolution{{Id: 1, ResolvedPci: 11, ResolvedConflicts: 1}, {Id: 2, ResolvedPci: 20, ResolvedConflicts: 1}}))
+	assert.Equal(t, 3.0, values["onos_xapppci_conflicts_detected_total"])
+	assert.Equal(t, 2.0, values["onos_xapppci_conflicts_resolved_total"])
+	assert.Equal(t, 1.0, values["onos_xapppci_pci_changes_total"])
+	assert.Equal(t, 30.0, values["onos_xapppci_pci_last_change_age_seconds"])
+
+	// The cells gone are forgotten.
+	values = kpiValues(t, history.update(t1.Add(30*time.Second), nil, nil))
+	assert.Equal(t, 0.0, values["onos_xapppci_conflicts_detected_total"])
+	assert.Equal(t, 0.0, values["onos_xapppci_unresolved_conflicts"])
+	assert.Len(t, history.cells, 0)
+}
+
+func Test_PciHistoryCounts(t *testing.T) {
+	type poll struct {
+		conflicts   []*pciapi.PciCell
+		resolutions []*pciapi.CellResolution
+	}
+	tests := []struct {
+		name     string
+		polls    []poll
+		detected float64
+		resolved float64
+	}{
+		{
+			name: "cell listed twice in conflicts",
+			polls: []poll{
+				{conflicts: []*pciapi.PciCell{{Id: 1}, {Id: 1}}},
+			},
+			detected: 1,
+		},
+		{
+			name: "cell listed twice then once",
+			polls: []poll{
+				{conflicts: []*pciapi.PciCell{{Id: 1}, {Id: 1}}},
+				{conflicts: []*pciapi.PciCell{{Id: 1}}},
+			},
+			detected: 1,
+		},
+		{
+			name: "resolved conflicts before first sight",
+			polls: []poll{
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 42}}},
+			},
+		},
+		{
+			name: "resolved conflicts since first sight",
+			polls: []poll{
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 42}}},
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 44}}},
+			},
+			resolved: 2,
+		},
+		{
+			name: "resolved conflicts before the first resolution of a cell first seen in conflict",
+			polls: []poll{
+				{conflicts: []*pciapi.PciCell{{Id: 1}}},
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 42}}},
+			},
+			detected: 1,
+		},
+		{
+			name: "resolved conflicts since the first resolution of a cell first seen in conflict",
+			polls: []poll{
+				{conflicts: []*pciapi.PciCell{{Id: 1}}},
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 42}}},
+				{resolutions: []*pciapi.CellResolution{{Id: 1, ResolvedPci: 10, ResolvedConflicts: 43}}},
+			},
+			detected: 1,
+			resolved: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			history := newPciHistory()
+			now := time.Unix(1630000000, 0)
+
+			var values map[string]float64
+			for _, p := range test.polls {
+				now = now.Add(10 * time.Second)
+				values = kpiValues(t, history.update(now, p.conflicts, p.resolutions))
+			}
+			assert.Equal(t, test.detected, values["onos_xapppci_conflicts_detected_total"])
+			assert.Equal(t, test.resolved, values["onos_xapppci_conflicts_resolved_total"])
+		})
+	}
+}
+
+func Test_ListNeighbors(t *testing.T) {
+	neighbors := listNeighbors([]*pciapi.PciCell{
+		{Id: 1, Pci: 10, Dlearfcn: 40000, NeighborIds: []uint64{2, 3, 4}},
+		{Id: 2, Pci: 10, Dlearfcn: 40000, NeighborIds: []uint64{1}},
+		{Id: 3, Pci: 10, Dlearfcn: 41000},
+	})
+
+	samples, err := neighbors.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 7)
+
+	samePci := map[string]string{}
+	for _, sample := range samples {
+		labels := map[string]string{}
+		for _, label := range sample.Labels {
+			labels[label.Name] = label.Value
+		}
+		if id, ok := labels["neighbor_id"]; ok && labels["cellid"] == "1" {
+			samePci[id] = labels["same_pci"] + "/" + labels["same_earfcn"]
+		}
+	}
+	assert.Equal(t, map[string]string{"2": "true/true", "3": "true/false", "4": "unknown/unknown"}, samePci)
+
+	values := kpiValues(t, neighbors)
+	assert.Equal(t, 4.0, values["onos_xapppci_neighbors"])
+}
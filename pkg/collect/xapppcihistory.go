@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package collect
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pciapi "github.com/onosproject/onos-api/go/onos/pci"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// pciCell keeps the state of a cell seen by the pci collector.
+type pciCell struct {
+	history     kpis.CellHistory
+	inConflict  bool
+	resolvedPci uint32
+	hasPci      bool
+	resolved    uint32
+	seeded      bool
+	changed     time.Time
+}
+
+// pciHistory keeps the history of the cells of a pci xapp service
+// across collects, to count the conflicts detected and resolved and
+// the PCI changes of each cell.
+// The cells missing from both the conflicts and the resolutions of a
+// collect are forgotten, so the history kept does not grow with the
+// cells that are gone.
+type pciHistory struct {
+	mu    sync.Mutex
+	cells map[string]*pciCell
+}
+
+func newPciHistory() *pciHistory {
+	return &pciHistory{
+		cells: make(map[string]*pciCell),
+	}
+}
+
+// update receives the cells in conflict and the cell resolutions of a
+// pci xapp service at time now, and stores the history of the cells
+// according to the data structure of the kpis.XappPciHistory KPI.
+// A conflict is detected when a cell enters the conflicts, once even
+// if the cell is listed more than once, and the conflicts resolved are
+// counted by the increase of the resolved conflicts of the cell since
+// its first resolution was seen, counting them all again if they decrease (e.g.,
+// the xapp restarted). A PCI change is a change of the resolved PCI
+// of the cell, the time since the last one is the time since the cell
+// was first seen until there is one.
+func (h *pciHistory) update(now time.Time, conflicts []*pciapi.PciCell, resolutions []*pciapi.CellResolution) kpis.KPI {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	historyKPI := kpis.XappPciHistory()
+	historyKPI.Cells = make(map[string]kpis.CellHistory)
+
+	cells := make(map[string]*pciCell)
+	cell := func(cellID string) *pciCell {
+		if c, ok := cells[cellID]; ok {
+			return c
+		}
+		c, ok := h.cells[cellID]
+		if !ok {
+			c = &pciCell{history: kpis.CellHistory{CellID: cellID}, changed: now}
+		}
+		cells[cellID] = c
+		return c
+	}
+
+	inConflict := make(map[string]bool)
+	for _, conflict := range conflicts {
+		cellID := fmt.Sprintf("%x", conflict.Id)
+		if inConflict[cellID] {
+			continue
+		}
+		inConflict[cellID] = true
+		c := cell(cellID)
+		if !c.inConflict {
+			c.history.ConflictsDetected++
+		}
+	}
+	historyKPI.Unresolved = len(inConflict)
+
+	for _, resolution := range resolutions {
+		cellID := fmt.Sprintf("%x", resolution.Id)
+		c := cell(cellID)
+
+		switch {
+		case !c.seeded:
+			// The conflicts resolved before the first resolution of
+			// the cell was seen (e.g., before the exporter started)
+			// are not counted.
+			c.seeded = true
+		case resolution.ResolvedConflicts >= c.resolved:
+			c.history.ConflictsResolved += uint64(resolution.ResolvedConflicts - c.resolved)
+		default:
+			c.history.ConflictsResolved += uint64(resolution.ResolvedConflicts)
+		}
+		c.resolved = resolution.ResolvedConflicts
+
+		if c.hasPci && resolution.ResolvedPci != c.resolvedPci {
+			c.history.PciChanges++
+			c.changed = now
+		}
+		c.resolvedPci = resolution.ResolvedPci
+		c.hasPci = true
+	}
+
+	for cellID, c := range cells {
+		c.inConflict = inConflict[cellID]
+		c.history.SinceLastPciChange = now.Sub(c.changed).Seconds()
+		historyKPI.Cells[cellID] = c.history
+	}
+	h.cells = cells
+
+	return historyKPI
+}
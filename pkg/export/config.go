@@ -0,0 +1,517 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/collect"
+	"github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/spf13/viper"
+)
+
+// Defaults of the exporter Config.
+const (
+	defaultAddress         = ":9861"
+	defaultPath            = "/metrics"
+	defaultMode            = "prometheus"
+	defaultInterval        = 15 * time.Second
+	defaultTimeout         = 10 * time.Second
+	defaultProfileInterval = 5 * time.Minute
+	defaultProfileTimeout  = 60 * time.Second
+)
+
+var (
+	// exporterModes defines the supported exporter modes.
+	exporterModes = []string{"prometheus"}
+
+	// watchCollectors defines the collectors that support watch mode.
+	watchCollectors = []string{config.ONOSTOPO, config.ONOSUENIB}
+
+	// kpmNoValuePolicies defines the policies for KPM measurements
+	// without value.
+	kpmNoValuePolicies = []string{collect.KPMNoValueSkip, collect.KPMNoValueNaN}
+
+	// limitPolicies defines the policies for label values over the
+	// maximum label length.
+	limitPolicies = []string{string(kpis.LimitTruncate), string(kpis.LimitDrop)}
+
+	// detailLevels defines the detail levels of the KPIs.
+	detailLevels = []string{string(kpis.DetailObject), string(kpis.DetailAggregate)}
+
+	// collectorKPIs defines the names of the KPIs of each collector.
+	collectorKPIs = map[string][]string{
+		config.ONOSE2T:        {"subscriptions", "subscriptions_count", "subscriptions_lifecycle", "channels", "channels_count"},
+		config.ONOSXAPPKPIMON: {"kpm"},
+		config.ONOSXAPPPCI:    {"info", "conflicts", "history", "neighbors"},
+		config.ONOSTOPO:       {"entities", "entities_count", "relations", "relations_count", "slices", "slices_count", "ran", "controls", "aspects"},
+		config.ONOSUENIB:      {"ues", "ues_count", "ue"},
+		config.ONOSPROFILE:    {"pprof"},
+	}
+)
+
+// DefaultConfig returns the Config used when none is provided.
+// All the collectors, but the profile one (which needs its targets),
+// are enabled with the default onos service endpoints.
+func DefaultConfig() Config {
+	return Config{
+		Address: defaultAddress,
+		Path:    defaultPath,
+		Mode:    defaultMode,
+		CollectorsConfigs: map[string]CollectorConfig{
+			config.ONOSE2T:        defaultCollectorConfig("onos-e2t:5150"),
+			config.ONOSXAPPPCI:    defaultCollectorConfig("onos-pci:5150"),
+			config.ONOSXAPPKPIMON: defaultCollectorConfig("onos-kpimon:5150"),
+			config.ONOSTOPO:       defaultCollectorConfig("onos-topo:5150"),
+			config.ONOSUENIB:      defaultCollectorConfig("onos-uenib:5150"),
+			config.ONOSPROFILE: {
+				Interval: defaultProfileInterval,
+				Timeout:  defaultProfileTimeout,
+			},
+		},
+	}
+}
+
+func defaultCollectorConfig(serviceAddress string) CollectorConfig {
+	return CollectorConfig{
+		Enabled:        true,
+		ServiceAddress: serviceAddress,
+		Interval:       defaultInterval,
+		Timeout:        defaultTimeout,
+	}
+}
+
+// LoadConfig reads the Config from a YAML or JSON file at path, on top
+// of DefaultConfig. The fields a collector does not set in the file
+// keep their default values, e.g., a collector listed in the file is
+// enabled unless it sets enabled to false.
+// The loaded Config is not validated, see Config.Validate.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	defaults := DefaultConfig()
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return cfg, fmt.Errorf("could not read config file %s: %s", path, err)
+	}
+
+	if err := v.UnmarshalExact(&cfg); err != nil {
+		return cfg, fmt.Errorf("could not decode config file %s: %s", path, err)
+	}
+
+	for name := range v.GetStringMap("collectors") {
+		colCfg := cfg.CollectorsConfigs[name]
+		key := "collectors." + name + "."
+		if !v.IsSet(key + "enabled") {
+			colCfg.Enabled = true
+		}
+
+		defaultColCfg, ok := defaults.CollectorsConfigs[name]
+		if !ok {
+			continue
+		}
+		if !v.IsSet(key + "endpoint") {
+			colCfg.ServiceAddress = defaultColCfg.ServiceAddress
+		}
+		if !v.IsSet(key + "interval") {
+			colCfg.Interval = defaultColCfg.Interval
+		}
+		if !v.IsSet(key + "timeout") {
+			colCfg.Timeout = defaultColCfg.Timeout
+		}
+		cfg.CollectorsConfigs[name] = colCfg
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the Config, returning an error that lists all
+// the problems found in it.
+func (c Config) Validate() error {
+	errs := []string{}
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if c.Address == "" {
+		addErr("address", "must not be empty")
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		addErr("path", "must start with / (got %q)", c.Path)
+	}
+	if !contains(exporterModes, c.Mode) {
+		addErr("mode", "must be one of %s (got %q)", strings.Join(exporterModes, ", "), c.Mode)
+	}
+	for _, err := range c.Web.validate() {
+		addErr("web", "%s", err)
+	}
+	for _, err := range c.TLS.validate() {
+		addErr("tls", "%s", err)
+	}
+	if c.TLS.ServerName != "" {
+		addErr("tls.serverName", "must be set in the tls section of a collector, it names a single onos service (got %q)", c.TLS.ServerName)
+	}
+	if c.Detail != "" && !contains(detailLevels, c.Detail) {
+		addErr("detail", "must be one of %s (got %q)", strings.Join(detailLevels, ", "), c.Detail)
+	}
+	for _, err := range c.Limits.validate() {
+		addErr("limits", "%s", err)
+	}
+
+	names := make([]string, 0, len(c.CollectorsConfigs))
+	for name := range c.CollectorsConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		colCfg := c.CollectorsConfigs[name]
+		field := "collectors." + name
+		if !contains(collectorNames, name) {
+			addErr(field, "unknown collector, must be one of %s", strings.Join(collectorNames, ", "))
+			continue
+		}
+		if !colCfg.Enabled {
+			continue
+		}
+		if colCfg.ServiceAddress == "" && colCfg.ConfigFile == "" {
+			addErr(field+".endpoint", "must not be empty for an enabled collector")
+		}
+		if colCfg.Interval < 0 {
+			addErr(field+".interval", "must not be negative (got %s)", colCfg.Interval)
+		}
+		if colCfg.Timeout < 0 {
+			addErr(field+".timeout", "must not be negative (got %s)", colCfg.Timeout)
+		}
+		if colCfg.Watch != nil && *colCfg.Watch && !contains(watchCollectors, name) {
+			addErr(field+".watch", "not supported, only by %s", strings.Join(watchCollectors, ", "))
+		}
+		if colCfg.ConfigFile != "" {
+			if _, err := os.Stat(colCfg.ConfigFile); err != nil {
+				addErr(field+".configFile", "could not access %s: %s", colCfg.ConfigFile, err)
+			} else if err := collect.ValidateConfigFile(name, colCfg.ConfigFile); err != nil {
+				addErr(field+".configFile", "%s", err)
+			}
+		}
+		for _, err := range colCfg.TLS.validate() {
+			addErr(field+".tls", "%s", err)
+		}
+		for _, err := range colCfg.KPM.validate() {
+			addErr(field+".kpm", "%s", err)
+		}
+		for _, err := range colCfg.Topo.validate() {
+			addErr(field+".topo", "%s", err)
+		}
+		if colCfg.Detail != "" && !contains(detailLevels, colCfg.Detail) {
+			addErr(field+".detail", "must be one of %s (got %q)", strings.Join(detailLevels, ", "), colCfg.Detail)
+		}
+		for kpiName, kpiCfg := range colCfg.KPIs {
+			if !contains(collectorKPIs[name], kpiName) {
+				addErr(field+".kpis."+kpiName, "unknown kpi, must be one of %s", strings.Join(collectorKPIs[name], ", "))
+				continue
+			}
+			if kpiCfg.Detail != "" && !contains(detailLevels, kpiCfg.Detail) {
+				addErr(field+".kpis."+kpiName+".detail", "must be one of %s (got %q)", strings.Join(detailLevels, ", "), kpiCfg.Detail)
+			}
+		}
+		for kpiName, limitCfg := range colCfg.Limits {
+			if !contains(collectorKPIs[name], kpiName) {
+				addErr(field+".limits."+kpiName, "unknown kpi, must be one of %s", strings.Join(collectorKPIs[name], ", "))
+				continue
+			}
+			for _, err := range limitCfg.validate() {
+				addErr(field+".limits."+kpiName, "%s", err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// validate returns the problems found in the TLSConfig.
+func (t TLSConfig) validate() []string {
+	errs := []string{}
+
+	if (t.CertPath == "") != (t.KeyPath == "") {
+		errs = append(errs, "certPath and keyPath must be set together")
+	}
+	for _, path := range []string{t.CAPath, t.CertPath, t.KeyPath} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Sprintf("could not access %s: %s", path, err))
+		}
+	}
+
+	return errs
+}
+
+// validate returns the problems found in the KPMConfig.
+func (k KPMConfig) validate() []string {
+	errs := []string{}
+
+	if k.NoValue != "" && !contains(kpmNoValuePolicies, k.NoValue) {
+		errs = append(errs, fmt.Sprintf("noValue must be one of %s (got %q)", strings.Join(kpmNoValuePolicies, ", "), k.NoValue))
+	}
+	if k.TimestampUnit < 0 {
+		errs = append(errs, fmt.Sprintf("timestampUnit must not be negative (got %s)", k.TimestampUnit))
+	}
+
+	measurements := map[string]bool{}
+	for i, m := range k.kpimonMetrics() {
+		field := fmt.Sprintf("metrics[%d]", i)
+		if m.Measurement == "" {
+			errs = append(errs, field+".measurement must not be empty")
+			continue
+		}
+		if measurements[m.Measurement] {
+			errs = append(errs, fmt.Sprintf("%s.measurement %s is duplicated", field, m.Measurement))
+		}
+		measurements[m.Measurement] = true
+		if m.Name != "" && !kpis.ValidMetricName(m.Name) {
+			errs = append(errs, fmt.Sprintf("%s.name %q is not a valid metric name", field, m.Name))
+		}
+		if name := m.MetricName(); !kpis.ValidMetricName(name) {
+			errs = append(errs, fmt.Sprintf("%s metric name %q of %s is not valid", field, name, m.Measurement))
+		}
+	}
+
+	return errs
+}
+
+// validate returns the problems found in the TopoConfig.
+func (t TopoConfig) validate() []string {
+	errs := []string{}
+
+	labelNames := map[string]string{}
+	for i, key := range t.Labels {
+		if key == "" {
+			errs = append(errs, fmt.Sprintf("labels[%d] must not be empty", i))
+			continue
+		}
+		name := kpis.TopoLabelName(key)
+		if other, ok := labelNames[name]; ok {
+			errs = append(errs, fmt.Sprintf("labels[%d] %s is promoted to %s like %s", i, key, name, other))
+		}
+		labelNames[name] = key
+	}
+
+	aspects := map[string]bool{}
+	aspectNames := map[string]string{}
+	for i, aspectType := range t.Aspects {
+		if aspectType == "" {
+			errs = append(errs, fmt.Sprintf("aspects[%d] must not be empty", i))
+			continue
+		}
+		if aspects[aspectType] {
+			errs = append(errs, fmt.Sprintf("aspects[%d] %s is duplicated", i, aspectType))
+			continue
+		}
+		aspects[aspectType] = true
+		name := kpis.SanitizeMetricName(aspectType)
+		if other, ok := aspectNames[name]; ok {
+			errs = append(errs, fmt.Sprintf("aspects[%d] %s is exported as the metric of %s", i, aspectType, other))
+		}
+		aspectNames[name] = aspectType
+	}
+
+	return errs
+}
+
+// validate returns the problems found in the LimitConfig.
+func (l LimitConfig) validate() []string {
+	errs := []string{}
+
+	if l.MaxLabelLength < -1 {
+		errs = append(errs, fmt.Sprintf("maxLabelLength must be positive, or -1 for no limit (got %d)", l.MaxLabelLength))
+	}
+	if l.MaxSeries < -1 {
+		errs = append(errs, fmt.Sprintf("maxSeries must be positive, or -1 for no limit (got %d)", l.MaxSeries))
+	}
+	if l.Policy != "" && !contains(limitPolicies, l.Policy) {
+		errs = append(errs, fmt.Sprintf("policy must be one of %s (got %q)", strings.Join(limitPolicies, ", "), l.Policy))
+	}
+
+	return errs
+}
+
+// orDefault returns the LimitConfig with the fields it does not set
+// taken from defaults.
+func (l LimitConfig) orDefault(defaults LimitConfig) LimitConfig {
+	if l.MaxLabelLength == 0 {
+		l.MaxLabelLength = defaults.MaxLabelLength
+	}
+	if l.MaxSeries == 0 {
+		l.MaxSeries = defaults.MaxSeries
+	}
+	if l.Policy == "" {
+		l.Policy = defaults.Policy
+	}
+	return l
+}
+
+// limits returns the LimitConfig as kpis.Limits, where no limit is
+// set by zero.
+func (l LimitConfig) limits() kpis.Limits {
+	limits := kpis.Limits{
+		MaxLabelLength: l.MaxLabelLength,
+		MaxSeries:      l.MaxSeries,
+		Policy:         kpis.LimitPolicy(l.Policy),
+	}
+	if limits.MaxLabelLength < 0 {
+		limits.MaxLabelLength = 0
+	}
+	if limits.MaxSeries < 0 {
+		limits.MaxSeries = 0
+	}
+	return limits
+}
+
+// kpiLimits defines the kpis.Limits of the KPIs of a collector by
+// KPI name, and the defaults of the KPIs not listed.
+type kpiLimits struct {
+	defaults kpis.Limits
+	kpis     map[string]kpis.Limits
+}
+
+// get returns the kpis.Limits of the KPI named kpiName.
+func (l kpiLimits) get(kpiName string) kpis.Limits {
+	if limits, ok := l.kpis[kpiName]; ok {
+		return limits
+	}
+	return l.defaults
+}
+
+// kpiLimits returns the kpiLimits of the collector, with the fields
+// it does not set taken from defaults.
+func (c CollectorConfig) kpiLimits(defaults LimitConfig) kpiLimits {
+	limits := kpiLimits{
+		defaults: defaults.limits(),
+		kpis:     make(map[string]kpis.Limits, len(c.Limits)),
+	}
+	for kpiName, limitCfg := range c.Limits {
+		limits.kpis[kpiName] = limitCfg.orDefault(defaults).limits()
+	}
+	return limits
+}
+
+// kpiFilter defines the KPIs of a collector that are exported.
+// The KPIs named in disabled are left out, and so are the KPIs with
+// a series per object at kpis.DetailAggregate, which is the detail
+// level of the KPIs named in details, or detail otherwise.
+type kpiFilter struct {
+	detail   kpis.DetailLevel
+	disabled map[string]bool
+	details  map[string]kpis.DetailLevel
+}
+
+// filter returns the KPIs of onosKPIs that are exported.
+func (f kpiFilter) filter(onosKPIs []kpis.KPI) []kpis.KPI {
+	exported := make([]kpis.KPI, 0, len(onosKPIs))
+
+	for _, kpi := range onosKPIs {
+		if f.exported(kpi) {
+			exported = append(exported, kpi)
+		}
+	}
+
+	return exported
+}
+
+// exported reports if kpi is exported. The KPIs without name (i.e.,
+// that are not a kpis.LimitedKPI) are always exported.
+// It is passed to the collectors too, so they skip the RPCs and the
+// computation of the KPIs that are not exported.
+func (f kpiFilter) exported(kpi kpis.KPI) bool {
+	namedKPI, ok := kpi.(kpis.LimitedKPI)
+	if !ok {
+		return true
+	}
+	if f.disabled[namedKPI.Name()] {
+		return false
+	}
+	detail, ok := f.details[namedKPI.Name()]
+	if !ok {
+		detail = f.detail
+	}
+	return detail != kpis.DetailAggregate || !kpis.ObjectKPI(kpi)
+}
+
+// kpiFilter returns the kpiFilter of the collector, at the detail
+// level defaultDetail if it does not set its own.
+func (c CollectorConfig) kpiFilter(defaultDetail string) kpiFilter {
+	detail := c.Detail
+	if detail == "" {
+		detail = defaultDetail
+	}
+	if detail == "" {
+		detail = string(kpis.DetailObject)
+	}
+
+	filter := kpiFilter{
+		detail:   kpis.DetailLevel(detail),
+		disabled: make(map[string]bool),
+		details:  make(map[string]kpis.DetailLevel),
+	}
+	for kpiName, kpiCfg := range c.KPIs {
+		if kpiCfg.Enabled != nil && !*kpiCfg.Enabled {
+			filter.disabled[kpiName] = true
+		}
+		if kpiCfg.Detail != "" {
+			filter.details[kpiName] = kpis.DetailLevel(kpiCfg.Detail)
+		}
+	}
+	return filter
+}
+
+// kpimonMetrics returns the Metrics of the KPMConfig as a list of
+// kpis.KpimonMetric.
+func (k KPMConfig) kpimonMetrics() []kpis.KpimonMetric {
+	metrics := make([]kpis.KpimonMetric, 0, len(k.Metrics))
+	for _, m := range k.Metrics {
+		metrics = append(metrics, kpis.KpimonMetric{
+			Measurement: m.Measurement,
+			Name:        m.Name,
+			Help:        m.Help,
+			Unit:        m.Unit,
+		})
+	}
+	return metrics
+}
+
+// orDefault returns the TLSConfig with the fields it does not set
+// taken from defaults. The client certificate and key are taken
+// together, so a certificate is not paired with the wrong key.
+// ServerName is not taken from defaults, as it names the service of a
+// single collector, which otherwise verifies the host of its endpoint.
+func (t TLSConfig) orDefault(defaults TLSConfig) TLSConfig {
+	if t.CAPath == "" {
+		t.CAPath = defaults.CAPath
+	}
+	if t.CertPath == "" && t.KeyPath == "" {
+		t.CertPath = defaults.CertPath
+		t.KeyPath = defaults.KeyPath
+	}
+	return t
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
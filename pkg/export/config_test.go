@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func Test_LoadConfig(t *testing.T) {
+	path := writeConfig(t, "exporter.yaml", `
+address: ":9999"
+background: true
+collectors:
+  onos-topo:
+    endpoint: topo:5150
+    interval: 1m
+  onos-e2t:
+    enabled: false
+    noTLS: false
+    tls:
+      certPath: /etc/onos/tls/tls.crt
+      keyPath: /etc/onos/tls/tls.key
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+
+	assert.Equal(t, ":9999", cfg.Address)
+	assert.Equal(t, "/metrics", cfg.Path)
+	assert.True(t, cfg.Background)
+
+	topo := cfg.CollectorsConfigs[config.ONOSTOPO]
+	assert.True(t, topo.Enabled)
+	assert.Equal(t, "topo:5150", topo.ServiceAddress)
+	assert.Equal(t, time.Minute, topo.Interval)
+	assert.Equal(t, defaultTimeout, topo.Timeout)
+
+	assert.Nil(t, topo.NoTLS)
+
+	e2t := cfg.CollectorsConfigs[config.ONOSE2T]
+	assert.False(t, e2t.Enabled)
+	if assert.NotNil(t, e2t.NoTLS) {
+		assert.False(t, *e2t.NoTLS)
+	}
+	assert.Equal(t, "/etc/onos/tls/tls.crt", e2t.TLS.CertPath)
+	assert.Equal(t, "/etc/onos/tls/tls.key", e2t.TLS.KeyPath)
+	assert.True(t, cfg.CollectorsConfigs[config.ONOSUENIB].Enabled)
+	assert.False(t, cfg.CollectorsConfigs[config.ONOSPROFILE].Enabled)
+}
+
+func Test_LoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "exporter.json", `{"collectors": {"onos-uenib": {"timeout": "3s"}}}`)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, cfg.CollectorsConfigs[config.ONOSUENIB].Timeout)
+}
+
+func Test_LoadConfigUnknownField(t *testing.T) {
+	path := writeConfig(t, "exporter.yaml", "adress: \":9999\"\n")
+
+	_, err := LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func Test_ValidateConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Path = "metrics"
+	cfg.CollectorsConfigs["onos-foo"] = CollectorConfig{}
+	cfg.CollectorsConfigs[config.ONOSPROFILE] = CollectorConfig{Enabled: true}
+	cfg.CollectorsConfigs[config.ONOSTOPO] = CollectorConfig{
+		Enabled:        true,
+		ServiceAddress: "onos-topo:5150",
+		TLS:            TLSConfig{CertPath: "/nonexistent/client.crt"},
+	}
+
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "path: must start with /")
+	assert.Contains(t, err.Error(), "collectors.onos-foo: unknown collector")
+	assert.Contains(t, err.Error(), "collectors.onos-profile.endpoint: must not be empty")
+	assert.Contains(t, err.Error(), "collectors.onos-topo.tls: certPath and keyPath must be set together")
+
+	cfg = DefaultConfig()
+	cfg.CollectorsConfigs[config.ONOSUENIB] = CollectorConfig{
+		Enabled:    true,
+		ConfigFile: writeConfig(t, "onos-uenib.yaml", "no-tls: flase\n"),
+	}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `collectors.onos-uenib.configFile: invalid onos-uenib config option no-tls: "flase" is not a boolean`)
+
+	cfg = DefaultConfig()
+	cfg.TLS.ServerName = "onos-topo"
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tls.serverName: must be set in the tls section of a collector")
+}
+
+func Test_TLSConfigDefault(t *testing.T) {
+	defaults := TLSConfig{CAPath: "ca.crt", CertPath: "tls.crt", KeyPath: "tls.key", ServerName: "onos-topo"}
+
+	// The collectors that do not set their serverName verify the host
+	// of their own endpoint.
+	assert.Equal(t, TLSConfig{CAPath: "ca.crt", CertPath: "tls.crt", KeyPath: "tls.key"}, TLSConfig{}.orDefault(defaults))
+	assert.Equal(t, TLSConfig{CAPath: "ca.crt", CertPath: "e2t.crt", KeyPath: "e2t.key", ServerName: "onos-e2t"},
+		TLSConfig{CertPath: "e2t.crt", KeyPath: "e2t.key", ServerName: "onos-e2t"}.orDefault(defaults))
+}
+
+func Test_LoadConfigKPMMetrics(t *testing.T) {
+	path := writeConfig(t, "exporter.yaml", `
+collectors:
+  onos-xappkpimon:
+    kpm:
+      ueSliceKeys: true
+      maxUESeries: -1
+      metrics:
+        - measurement: DRB.UEThpDl
+          name: drb_ue_throughput_dl
+          help: The DL UE throughput
+          unit: kbps
+        - measurement: RRC.Conn.Avg
+          name: rrc.conn
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	kpm := cfg.CollectorsConfigs[config.ONOSXAPPKPIMON].KPM
+	assert.True(t, kpm.UESliceKeys)
+	assert.Equal(t, -1, kpm.MaxUESeries)
+
+	metrics := kpm.Metrics
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, "DRB.UEThpDl", metrics[0].Measurement)
+	assert.Equal(t, "kbps", metrics[0].Unit)
+
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `collectors.onos-xappkpimon.kpm: metrics[1].name "rrc.conn" is not a valid metric name`)
+}
+
+func Test_LoadConfigLimits(t *testing.T) {
+	path := writeConfig(t, "exporter.yaml", `
+limits:
+  maxLabelLength: 128
+  maxSeries: 1000
+collectors:
+  onos-topo:
+    limits:
+      slices:
+        maxLabelLength: -1
+        policy: drop
+      entities:
+        maxSeries: -5
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "collectors.onos-topo.limits.entities: maxSeries must be positive, or -1 for no limit")
+
+	limits := cfg.CollectorsConfigs[config.ONOSTOPO].kpiLimits(cfg.Limits)
+	assert.Equal(t, kpis.Limits{MaxSeries: 1000, Policy: kpis.LimitDrop}, limits.get("slices"))
+	assert.Equal(t, kpis.Limits{MaxLabelLength: 128, MaxSeries: 1000}, limits.get("relations"))
+}
+
+func Test_ValidateTopoConfig(t *testing.T) {
+	assert.Empty(t, TopoConfig{Labels: []string{"plmnid", "tac"}, Aspects: []string{"onos.topo.Location"}}.validate())
+
+	errs := TopoConfig{Labels: []string{"onos.kind", "onos-kind", ""}, Aspects: []string{"onos.topo.E2Node", "onos.topo.E2Node"}}.validate()
+	assert.Len(t, errs, 3)
+
+	errs = TopoConfig{Aspects: []string{"onos.topo.E2Node", "onos-topo-e2node"}}.validate()
+	assert.Equal(t, []string{"aspects[1] onos-topo-e2node is exported as the metric of onos.topo.E2Node"}, errs)
+}
+
+func Test_KPIFilter(t *testing.T) {
+	path := writeConfig(t, "exporter.yaml", `
+detail: aggregate
+collectors:
+  onos-topo:
+    kpis:
+      relations_count:
+        enabled: false
+      slices:
+        detail: object
+  onos-uenib:
+    detail: object
+    kpis:
+      ue_changes:
+        enabled: false
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "collectors.onos-uenib.kpis.ue_changes: unknown kpi")
+
+	topoKPIs := []kpis.KPI{
+		kpis.OnosTopoEntities(), kpis.OnosTopoEntityCounts(),
+		kpis.OnosTopoRelations(), kpis.OnosTopoRelationCounts(),
+		kpis.OnosTopoSlices(), kpis.OnosTopoSliceCounts(),
+	}
+	exported := cfg.CollectorsConfigs[config.ONOSTOPO].kpiFilter(cfg.Detail).filter(topoKPIs)
+	assert.Equal(t, []kpis.KPI{topoKPIs[1], topoKPIs[4], topoKPIs[5]}, exported)
+
+	uenibKPIs := []kpis.KPI{kpis.OnosUenibUEs(), kpis.OnosUenibUECounts()}
+	assert.Equal(t, uenibKPIs, cfg.CollectorsConfigs[config.ONOSUENIB].kpiFilter(cfg.Detail).filter(uenibKPIs))
+
+	pciKPIs := []kpis.KPI{
+		kpis.XappPciNumConflicts(), kpis.XappPciResolvedConflicts(),
+		kpis.XappPciHistory(), kpis.XappPciNeighbors(),
+	}
+	exported = cfg.CollectorsConfigs[config.ONOSXAPPPCI].kpiFilter(cfg.Detail).filter(pciKPIs)
+	assert.Equal(t, []kpis.KPI{pciKPIs[0], pciKPIs[1]}, exported)
+}
+
+func Test_CollectorKPIs(t *testing.T) {
+	factories := map[string][]kpis.LimitedKPI{
+		config.ONOSE2T:        {kpis.OnosE2tSubscriptions(), kpis.OnosE2tSubscriptionCounts(), kpis.OnosE2tSubscriptionLifecycle(), kpis.OnosE2tChannels(), kpis.OnosE2tChannelCounts()},
+		config.ONOSXAPPKPIMON: {kpis.XappKpiMon()},
+		config.ONOSXAPPPCI:    {kpis.XappPciNumConflicts(), kpis.XappPciResolvedConflicts(), kpis.XappPciHistory(), kpis.XappPciNeighbors()},
+		config.ONOSTOPO: {
+			kpis.OnosTopoEntities(), kpis.OnosTopoEntityCounts(),
+			kpis.OnosTopoRelations(), kpis.OnosTopoRelationCounts(),
+			kpis.OnosTopoSlices(), kpis.OnosTopoSliceCounts(),
+			kpis.OnosTopoRAN(), kpis.OnosTopoE2Controls(), kpis.OnosTopoAspects(),
+		},
+		config.ONOSUENIB:   {kpis.OnosUenibUEs(), kpis.OnosUenibUECounts(), kpis.OnosUenibUEChanges()},
+		config.ONOSPROFILE: {kpis.OnosProfileHeap()},
+	}
+
+	for name, collectorFactories := range factories {
+		names := []string{}
+		for _, kpi := range collectorFactories {
+			names = append(names, kpi.Name())
+		}
+		assert.Equal(t, collectorKPIs[name], names, name)
+	}
+}
@@ -0,0 +1,356 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+)
+
+// Encoder encodes the samples of the metrics served by the exporter
+// in the format of its ContentType.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, samples []kpis.Sample) error
+}
+
+// encoders are the encoders the exporter can serve the metrics with,
+// by the media type of their format. The first one is the default.
+var encoders = []struct {
+	mediaType string
+	encoder   Encoder
+}{
+	{"text/plain", prometheusTextEncoder{}},
+	{"application/openmetrics-text", openMetricsEncoder{}},
+	{"application/json", jsonEncoder{}},
+}
+
+// negotiateEncoder returns the encoder of the media type preferred by
+// the Accept header of a request, by its quality (q) and then by its
+// order in the header, the default encoder if none is accepted.
+// A wildcard media range matches the first encoder of its media types.
+func negotiateEncoder(accept string) Encoder {
+	var best Encoder
+	bestQuality := 0.0
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= bestQuality {
+			continue
+		}
+
+		for _, e := range encoders {
+			if mediaRangeMatches(mediaType, e.mediaType) {
+				best, bestQuality = e.encoder, quality
+				break
+			}
+		}
+	}
+
+	if best == nil {
+		return encoders[0].encoder
+	}
+	return best
+}
+
+// mediaRangeMatches reports if the media range of an Accept header,
+// which can be a wildcard (e.g., text/* or */*), matches mediaType.
+func mediaRangeMatches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	return strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+}
+
+// family defines the samples of a metric family, in the order they
+// were added.
+type family struct {
+	name    string
+	help    string
+	typ     kpis.SampleType
+	unit    string
+	samples []kpis.Sample
+}
+
+// families groups the samples by the name of their metric, taking the
+// HELP text, type and unit of a family from its first sample. The
+// families are sorted by name.
+// The name of a counter family must end with _total, and the name of
+// an info family with _info, so their samples are named the same in
+// every format. The families that do not are left out, and reported
+// in the error returned along with the other families.
+func families(samples []kpis.Sample) ([]*family, error) {
+	byName := make(map[string]*family)
+	names := []string{}
+	for _, sample := range samples {
+		f, ok := byName[sample.Name]
+		if !ok {
+			f = &family{name: sample.Name, help: sample.Help, typ: sample.Type, unit: sample.Unit}
+			byName[sample.Name] = f
+			names = append(names, sample.Name)
+		}
+		f.samples = append(f.samples, sample)
+	}
+
+	sort.Strings(names)
+	sorted := make([]*family, 0, len(names))
+	invalid := []string{}
+	for _, name := range names {
+		if suffix, ok := familySuffixes[byName[name].typ]; ok && !strings.HasSuffix(name, suffix) {
+			invalid = append(invalid, name)
+			continue
+		}
+		sorted = append(sorted, byName[name])
+	}
+
+	if len(invalid) > 0 {
+		return sorted, fmt.Errorf("%d families not encoded, without the suffix of their type: %s", len(invalid), strings.Join(invalid, ", "))
+	}
+	return sorted, nil
+}
+
+// familySuffixes are the suffixes the name of a family of a type must
+// end with.
+var familySuffixes = map[kpis.SampleType]string{
+	kpis.SampleCounter: "_total",
+	kpis.SampleInfo:    "_info",
+}
+
+// formatFloat formats a sample value as the text formats do.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, +1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+// writeSample writes a sample line of the text formats, with the
+// name given and the timestamp already formatted, if any.
+func writeSample(w *bufio.Writer, name string, labels []kpis.Label, value float64, timestamp string, escape *strings.Replacer) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label.Name)
+			w.WriteString(`="`)
+			w.WriteString(escape.Replace(label.Value))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	if timestamp != "" {
+		w.WriteByte(' ')
+		w.WriteString(timestamp)
+	}
+	w.WriteByte('\n')
+}
+
+var (
+	// labelValueEscaper escapes the label values of the text formats.
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	// prometheusHelpEscaper escapes the HELP text of the Prometheus
+	// text format.
+	prometheusHelpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// prometheusTextEncoder encodes the samples in the Prometheus text
+// format (version 0.0.4). The info metrics are encoded as gauges, and
+// the metrics of unknown type as untyped.
+type prometheusTextEncoder struct{}
+
+// ContentType implements the Encoder interface for prometheusTextEncoder.
+func (prometheusTextEncoder) ContentType() string {
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+
+// Encode implements the Encoder interface for prometheusTextEncoder.
+func (prometheusTextEncoder) Encode(w io.Writer, samples []kpis.Sample) error {
+	bw := bufio.NewWriter(w)
+
+	sampleFamilies, err := families(samples)
+	for _, f := range sampleFamilies {
+		typ := string(f.typ)
+		switch f.typ {
+		case kpis.SampleInfo:
+			typ = string(kpis.SampleGauge)
+		case kpis.SampleUnknown:
+			typ = "untyped"
+		}
+
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.name, prometheusHelpEscaper.Replace(f.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, typ)
+
+		for _, sample := range f.samples {
+			timestamp := ""
+			if !sample.Timestamp.IsZero() {
+				timestamp = strconv.FormatInt(sample.Timestamp.UnixNano()/int64(time.Millisecond), 10)
+			}
+			writeSample(bw, sample.Name+sample.Suffix, sample.Labels, sample.Value, timestamp, labelValueEscaper)
+		}
+	}
+
+	if flushErr := bw.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// openMetricsEncoder encodes the samples in the OpenMetrics text format
+// (version 1.0.0). The family of a counter or an info metric is named
+// without its _total or _info suffix, which is kept in the name of its
+// samples, and the unit of a family is set with a UNIT line.
+// A family named without its suffix would clash with another family
+// (e.g., the go_memstats_alloc_bytes_total counter and the
+// go_memstats_alloc_bytes gauge), so it is encoded as unknown with its
+// full name instead.
+type openMetricsEncoder struct{}
+
+// ContentType implements the Encoder interface for openMetricsEncoder.
+func (openMetricsEncoder) ContentType() string {
+	return "application/openmetrics-text; version=1.0.0; charset=utf-8"
+}
+
+// Encode implements the Encoder interface for openMetricsEncoder.
+func (openMetricsEncoder) Encode(w io.Writer, samples []kpis.Sample) error {
+	bw := bufio.NewWriter(w)
+
+	sampleFamilies, err := families(samples)
+
+	names := make(map[string]bool, len(sampleFamilies))
+	for _, f := range sampleFamilies {
+		if _, ok := familySuffixes[f.typ]; !ok {
+			names[f.name] = true
+		}
+	}
+
+	for _, f := range sampleFamilies {
+		typ, suffix := f.typ, familySuffixes[f.typ]
+		name := strings.TrimSuffix(f.name, suffix)
+		if suffix != "" {
+			if names[name] {
+				typ, suffix, name = kpis.SampleUnknown, "", f.name
+			}
+			names[name] = true
+		}
+
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		if f.unit != "" && strings.HasSuffix(name, "_"+f.unit) {
+			fmt.Fprintf(bw, "# UNIT %s %s\n", name, f.unit)
+		}
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, labelValueEscaper.Replace(f.help))
+		}
+
+		for _, sample := range f.samples {
+			timestamp := ""
+			if !sample.Timestamp.IsZero() {
+				timestamp = strconv.FormatFloat(float64(sample.Timestamp.UnixNano())/float64(time.Second), 'f', -1, 64)
+			}
+			sampleSuffix := suffix
+			if sample.Suffix != "" {
+				sampleSuffix = sample.Suffix
+			}
+			writeSample(bw, name+sampleSuffix, sample.Labels, sample.Value, timestamp, labelValueEscaper)
+		}
+	}
+
+	bw.WriteString("# EOF\n")
+	if flushErr := bw.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// jsonEncoder encodes the samples in JSON, as a list of samples with
+// their labels as an object. The values that are not finite numbers
+// are encoded as the strings NaN, +Inf and -Inf.
+type jsonEncoder struct{}
+
+// jsonSample defines the JSON encoding of a kpis.Sample.
+type jsonSample struct {
+	Name      string            `json:"name"`
+	Help      string            `json:"help,omitempty"`
+	Type      kpis.SampleType   `json:"type"`
+	Unit      string            `json:"unit,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	Value     interface{}       `json:"value"`
+	Timestamp *time.Time        `json:"timestamp,omitempty"`
+}
+
+// ContentType implements the Encoder interface for jsonEncoder.
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode implements the Encoder interface for jsonEncoder.
+func (jsonEncoder) Encode(w io.Writer, samples []kpis.Sample) error {
+	encoded := struct {
+		Samples []jsonSample `json:"samples"`
+	}{
+		Samples: []jsonSample{},
+	}
+
+	sampleFamilies, err := families(samples)
+	for _, f := range sampleFamilies {
+		for _, sample := range f.samples {
+			s := jsonSample{
+				Name:   sample.Name + sample.Suffix,
+				Help:   sample.Help,
+				Type:   sample.Type,
+				Unit:   sample.Unit,
+				Labels: make(map[string]string, len(sample.Labels)),
+				Value:  sample.Value,
+			}
+			for _, label := range sample.Labels {
+				s.Labels[label.Name] = label.Value
+			}
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				s.Value = formatFloat(sample.Value)
+			}
+			if !sample.Timestamp.IsZero() {
+				timestamp := sample.Timestamp
+				s.Timestamp = &timestamp
+			}
+			encoded.Samples = append(encoded.Samples, s)
+		}
+	}
+
+	if encodeErr := json.NewEncoder(w).Encode(encoded); encodeErr != nil {
+		return encodeErr
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSamples = []kpis.Sample{
+	{
+		Name:   "onos_test_requests_total",
+		Help:   "The requests",
+		Type:   kpis.SampleCounter,
+		Labels: []kpis.Label{{Name: "code", Value: "200"}},
+		Value:  3,
+	},
+	{
+		Name:      "onos_test_age_seconds",
+		Help:      "The age",
+		Type:      kpis.SampleGauge,
+		Unit:      "seconds",
+		Labels:    []kpis.Label{{Name: "id", Value: `a"b`}},
+		Value:     1.5,
+		Timestamp: time.Unix(1630000000, 5e8),
+	},
+	{
+		Name:   "onos_test_build_info",
+		Help:   "The build",
+		Type:   kpis.SampleInfo,
+		Labels: []kpis.Label{{Name: "version", Value: "v1"}},
+		Value:  1,
+	},
+	{
+		Name:  "onos_test_ratio",
+		Type:  kpis.SampleGauge,
+		Value: math.NaN(),
+	},
+}
+
+func Test_NegotiateEncoder(t *testing.T) {
+	assert.Equal(t, prometheusTextEncoder{}, negotiateEncoder(""))
+	assert.Equal(t, prometheusTextEncoder{}, negotiateEncoder("text/html"))
+	assert.Equal(t, prometheusTextEncoder{}, negotiateEncoder("*/*"))
+	assert.Equal(t, jsonEncoder{}, negotiateEncoder("application/json"))
+	assert.Equal(t, openMetricsEncoder{}, negotiateEncoder("application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"))
+	assert.Equal(t, jsonEncoder{}, negotiateEncoder("application/openmetrics-text;q=0.2, application/json;q=0.8"))
+	assert.Equal(t, openMetricsEncoder{}, negotiateEncoder("application/*"))
+	assert.Equal(t, jsonEncoder{}, negotiateEncoder("bad;;, application/json"))
+}
+
+func Test_PrometheusTextEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, prometheusTextEncoder{}.Encode(&buf, testSamples))
+	assert.Equal(t, `# HELP onos_test_age_seconds The age
+# TYPE onos_test_age_seconds gauge
+onos_test_age_seconds{id="a\"b"} 1.5 1630000000500
+# HELP onos_test_build_info The build
+# TYPE onos_test_build_info gauge
+onos_test_build_info{version="v1"} 1
+# TYPE onos_test_ratio gauge
+onos_test_ratio NaN
+# HELP onos_test_requests_total The requests
+# TYPE onos_test_requests_total counter
+onos_test_requests_total{code="200"} 3
+`, buf.String())
+}
+
+func Test_OpenMetricsEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, openMetricsEncoder{}.Encode(&buf, testSamples))
+	assert.Equal(t, `# TYPE onos_test_age_seconds gauge
+# UNIT onos_test_age_seconds seconds
+# HELP onos_test_age_seconds The age
+onos_test_age_seconds{id="a\"b"} 1.5 1630000000.5
+# TYPE onos_test_build info
+# HELP onos_test_build The build
+onos_test_build_info{version="v1"} 1
+# TYPE onos_test_ratio gauge
+onos_test_ratio NaN
+# TYPE onos_test_requests counter
+# HELP onos_test_requests The requests
+onos_test_requests_total{code="200"} 3
+# EOF
+`, buf.String())
+}
+
+func Test_JSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, jsonEncoder{}.Encode(&buf, testSamples))
+
+	var decoded struct {
+		Samples []map[string]interface{} `json:"samples"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Len(t, decoded.Samples, 4)
+	assert.Equal(t, "onos_test_age_seconds", decoded.Samples[0]["name"])
+	assert.Equal(t, "seconds", decoded.Samples[0]["unit"])
+	assert.Equal(t, map[string]interface{}{"id": `a"b`}, decoded.Samples[0]["labels"])
+	assert.Equal(t, 1.5, decoded.Samples[0]["value"])
+	assert.Contains(t, decoded.Samples[0], "timestamp")
+	assert.Equal(t, "info", decoded.Samples[1]["type"])
+	assert.Equal(t, "NaN", decoded.Samples[2]["value"])
+	assert.NotContains(t, decoded.Samples[2], "timestamp")
+}
+
+func Test_FamilySuffixes(t *testing.T) {
+	samples := []kpis.Sample{
+		{Name: "onos_test_requests", Type: kpis.SampleCounter, Value: 3},
+		{Name: "onos_test_build", Type: kpis.SampleInfo, Value: 1},
+		{Name: "onos_test_up", Type: kpis.SampleGauge, Value: 1},
+	}
+
+	for _, encoder := range []Encoder{prometheusTextEncoder{}, openMetricsEncoder{}, jsonEncoder{}} {
+		var buf bytes.Buffer
+		err := encoder.Encode(&buf, samples)
+		assert.EqualError(t, err, "2 families not encoded, without the suffix of their type: onos_test_build, onos_test_requests")
+		assert.Contains(t, buf.String(), "onos_test_up")
+		assert.NotContains(t, buf.String(), "onos_test_requests")
+		assert.NotContains(t, buf.String(), "onos_test_build")
+	}
+}
+
+func Test_OpenMetricsNameClash(t *testing.T) {
+	samples := []kpis.Sample{
+		{Name: "onos_test_alloc_bytes_total", Type: kpis.SampleCounter, Unit: "bytes", Value: 5},
+		{Name: "onos_test_alloc_bytes", Type: kpis.SampleGauge, Unit: "bytes", Value: 2},
+		{Name: "onos_test_build_info", Type: kpis.SampleInfo, Value: 1},
+		{Name: "onos_test_build_total", Type: kpis.SampleCounter, Value: 3},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, openMetricsEncoder{}.Encode(&buf, samples))
+	assert.Equal(t, `# TYPE onos_test_alloc_bytes gauge
+# UNIT onos_test_alloc_bytes bytes
+onos_test_alloc_bytes 2
+# TYPE onos_test_alloc_bytes_total unknown
+onos_test_alloc_bytes_total 5
+# TYPE onos_test_build info
+onos_test_build_info 1
+# TYPE onos_test_build_total unknown
+onos_test_build_total 3
+# EOF
+`, buf.String())
+
+	// The default metrics have the go_memstats_alloc_bytes gauge and
+	// the go_memstats_alloc_bytes_total counter.
+	samples, err := gatherSamples(prometheus.DefaultGatherer)
+	assert.NoError(t, err)
+	buf.Reset()
+	assert.NoError(t, openMetricsEncoder{}.Encode(&buf, samples))
+
+	types := make(map[string]bool)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			name := strings.Fields(line)[2]
+			assert.False(t, types[name], "duplicate family %s", name)
+			types[name] = true
+		}
+	}
+	assert.True(t, types["go_memstats_alloc_bytes"])
+	assert.True(t, types["go_memstats_alloc_bytes_total"])
+}
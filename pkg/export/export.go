@@ -4,12 +4,144 @@
 
 package export
 
+import "time"
+
+// TLSConfig states the certificates used by a Collector to connect
+// to its onos service.
+// CertPath and KeyPath define the client certificate and key, and
+// CAPath the CA certificate used to verify the service certificate
+// (not verified if CAPath is empty). ServerName overrides the name
+// verified in the service certificate (the host of the endpoint of the
+// collector if empty), it is only set in the TLSConfig of a collector.
+type TLSConfig struct {
+	CAPath     string `mapstructure:"caPath"`
+	CertPath   string `mapstructure:"certPath"`
+	KeyPath    string `mapstructure:"keyPath"`
+	ServerName string `mapstructure:"serverName"`
+}
+
+// WebConfig states the security of the exporter endpoint.
+// CertPath and KeyPath define the server certificate and key to serve
+// the endpoint over HTTPS (plain HTTP if not set), and ClientCAPath
+// the CA certificate that client certificates must be signed by
+// (not required if empty).
+// Username and PasswordFile define the basic auth credentials, and
+// TokenFile a bearer token, that requests must carry (either one if
+// both are set). The password and token are read from files, so they
+// are kept out of the config file and the command line.
+type WebConfig struct {
+	CertPath     string `mapstructure:"certPath"`
+	KeyPath      string `mapstructure:"keyPath"`
+	ClientCAPath string `mapstructure:"clientCAPath"`
+	Username     string `mapstructure:"username"`
+	PasswordFile string `mapstructure:"passwordFile"`
+	TokenFile    string `mapstructure:"tokenFile"`
+}
+
+// KPMConfig states how the kpimon collector exports the KPM
+// measurements. NoValue defines if the measurements without value
+// are skipped ("skip", the default) or exported as NaN ("nan").
+// TimestampUnit defines the unit of the unix timestamps of the
+// measurement records (seconds if zero), e.g., 1ms.
+// Metrics maps measurement names to the name, HELP text and unit of
+// their metrics, overriding the default table.
+// UESliceKeys parses the UE and slice of the per-UE and per-slice
+// measurements from the :ue=<id> and :slice=<id> segments of their
+// keys, which need a kpimon that reports them.
+// MaxUESeries caps the number of per-UE measurements exported (1000
+// if zero, no cap if negative).
+type KPMConfig struct {
+	NoValue       string            `mapstructure:"noValue"`
+	TimestampUnit time.Duration     `mapstructure:"timestampUnit"`
+	Metrics       []KPMMetricConfig `mapstructure:"metrics"`
+	UESliceKeys   bool              `mapstructure:"ueSliceKeys"`
+	MaxUESeries   int               `mapstructure:"maxUESeries"`
+}
+
+// KPMMetricConfig states how a KPM measurement is exported.
+// Name is the name of its metric (the sanitized measurement name if
+// empty, e.g., rrc_conn_avg for RRC.Conn.Avg), Help its HELP text and
+// Unit its unit, appended to the name as a suffix, e.g., bytes.
+type KPMMetricConfig struct {
+	Measurement string `mapstructure:"measurement"`
+	Name        string `mapstructure:"name"`
+	Help        string `mapstructure:"help"`
+	Unit        string `mapstructure:"unit"`
+}
+
+// TopoConfig states how the topo collector exports the topo objects.
+// Labels lists the keys of the topo labels promoted to Prometheus
+// labels of the entities and relations, named label_<key> (e.g.,
+// plmnid as label_plmnid), and Aspects the aspect types exported as
+// info metrics (e.g., onos.topo.Location), with the scalar fields of
+// each aspect as labels.
+type TopoConfig struct {
+	Labels  []string `mapstructure:"labels"`
+	Aspects []string `mapstructure:"aspects"`
+}
+
+// KPIConfig states how a KPI of a collector is exported. Enabled
+// set to false leaves the KPI out, and Detail overrides the detail
+// level of the collector for the KPI.
+type KPIConfig struct {
+	Enabled *bool  `mapstructure:"enabled"`
+	Detail  string `mapstructure:"detail"`
+}
+
+// LimitConfig states the cardinality limits of the metrics of a KPI.
+// MaxLabelLength limits the length (in bytes) of the label values,
+// and MaxSeries the number of series of each metric. Policy defines
+// if the series with a label value over MaxLabelLength are truncated
+// ("truncate", the default) or dropped ("drop"). The series beyond
+// MaxSeries are always dropped.
+// A field not set (zero) is taken from the defaults the LimitConfig
+// is merged with, and -1 sets no limit.
+type LimitConfig struct {
+	MaxLabelLength int    `mapstructure:"maxLabelLength"`
+	MaxSeries      int    `mapstructure:"maxSeries"`
+	Policy         string `mapstructure:"policy"`
+}
+
 // CollectorConfig states the parameters that enables a Collector.
+// Enabled defines if the Collector is created at all, ServiceAddress
+// the endpoint of its onos service, and TLS its certificates (each
+// field not set is taken from the TLS of Config), unless NoTLS is true.
+// ConfigFile optionally defines a file with the collector options
+// (e.g., service-address, tls.certPath, tls.keyPath, no-tls), which
+// the other fields set override.
+// Interval defines how often the Collector is polled when the
+// exporter runs in background mode, and Timeout limits the duration
+// of each poll (no limit if zero). A scrape is limited by the
+// Prometheus scrape timeout too.
+// Watch enables the watch mode of the collectors that support it,
+// which keep a replica of the state of their onos service up to date
+// with its events, instead of listing it on every poll.
+// NoTLS and Watch are not set if nil, so the values of ConfigFile are
+// used, and override them if set, even to false.
+// KPM defines how the kpimon collector exports KPM measurements, and
+// Topo how the topo collector exports the topo objects.
+// Detail defines the detail level of the KPIs of the Collector, i.e.,
+// if the KPIs with a series per object (e.g., entities) are exported
+// ("object") or only their aggregates (e.g., entities_count) are
+// ("aggregate"), the Detail of Config if not set. KPIs defines how
+// each KPI of the Collector is exported by KPI name.
+// Limits defines the cardinality limits of the KPIs of the Collector
+// by KPI name (e.g., slices, entities), each field not set is taken
+// from the Limits of Config.
 type CollectorConfig struct {
-	ServiceAddress string
-	CAPath         string
-	KeyPath        string
-	CertPath       string
+	Enabled        bool                   `mapstructure:"enabled"`
+	ServiceAddress string                 `mapstructure:"endpoint"`
+	TLS            TLSConfig              `mapstructure:"tls"`
+	NoTLS          *bool                  `mapstructure:"noTLS"`
+	Interval       time.Duration          `mapstructure:"interval"`
+	Timeout        time.Duration          `mapstructure:"timeout"`
+	Watch          *bool                  `mapstructure:"watch"`
+	KPM            KPMConfig              `mapstructure:"kpm"`
+	Topo           TopoConfig             `mapstructure:"topo"`
+	Detail         string                 `mapstructure:"detail"`
+	KPIs           map[string]KPIConfig   `mapstructure:"kpis"`
+	Limits         map[string]LimitConfig `mapstructure:"limits"`
+	ConfigFile     string                 `mapstructure:"configFile"`
 }
 
 // Config establishes the fields needed for the instantiation of
@@ -18,36 +150,51 @@ type CollectorConfig struct {
 // be pulled or pushed.
 // Mode defines the exporter mode, i.e., the exporter implementation mode,
 // for instance, prometheus.
-// CAPath, KeyPath and CertPath are defined by the utilization of
-// a northbound implementation of needed certificates for an exporter.
+// Background defines if the collectors are polled in the background,
+// each one on its own interval, so the exporter only serves their
+// latest KPIs instead of polling all of them on every retrieval.
+// Web defines the TLS and authentication of the exporter endpoint.
+// TLS defines the certificates used by the collectors that do not
+// define their own.
+// Detail defines the default detail level of the KPIs of all the
+// collectors, "object" if not set.
+// Limits defines the default cardinality limits of the KPIs of all
+// the collectors, none if not set.
 // The remaining fields define the needed data needed for the exporters,
 // those fields can be defined in their own structs if needed.
 type Config struct {
-	Address           string
-	Path              string
-	Mode              string
-	CAPath            string
-	KeyPath           string
-	CertPath          string
-	CollectorsConfigs map[string]CollectorConfig
+	Address           string                     `mapstructure:"address"`
+	Path              string                     `mapstructure:"path"`
+	Mode              string                     `mapstructure:"mode"`
+	Background        bool                       `mapstructure:"background"`
+	Web               WebConfig                  `mapstructure:"web"`
+	TLS               TLSConfig                  `mapstructure:"tls"`
+	Detail            string                     `mapstructure:"detail"`
+	Limits            LimitConfig                `mapstructure:"limits"`
+	CollectorsConfigs map[string]CollectorConfig `mapstructure:"collectors"`
 }
 
-// exporter defines the behavior expected from an exporter.
-type exporter interface {
+// Exporter defines the behavior expected from an exporter.
+// Run serves the exporter until it fails, and Close releases
+// the resources of its collectors when the exporter shuts down.
+type Exporter interface {
 	Run() error
+	Close() error
 }
 
-// NewExporter defines a factory for an exporter interface.
+// NewExporter defines a factory for an Exporter interface.
 // PrometheusExporter realizes that interface behavior.
 // Other exporters can be added similarly. Turning the implementation
 // of onos-exporter independent from a single exporter.
-func NewExporter(cfg Config) exporter {
+// An error is returned if the exporter endpoint can not be secured
+// as set in the Web field of cfg.
+func NewExporter(cfg Config) (Exporter, error) {
 	switch cfg.Mode {
 	case "prometheus":
 		log.Info("Creating prometheus exporter")
-		return PrometheusExporter(cfg)
 	default:
 		log.Info("Creating default exporter (prometheus)")
-		return PrometheusExporter(cfg)
 	}
+
+	return PrometheusExporter(cfg)
 }
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherSamples gathers the metrics of gatherer (e.g., the golang
+// metrics of the prometheus default registry) as samples, with the
+// series of its summaries and histograms as samples of their family.
+// The metrics gathered along with an error are returned too.
+func gatherSamples(gatherer prometheus.Gatherer) ([]kpis.Sample, error) {
+	metricFamilies, err := gatherer.Gather()
+
+	samples := []kpis.Sample{}
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.GetMetric() {
+			samples = append(samples, familySamples(metricFamily, metric)...)
+		}
+	}
+
+	return samples, err
+}
+
+// familySamples returns the samples of a metric of metricFamily.
+func familySamples(metricFamily *dto.MetricFamily, metric *dto.Metric) []kpis.Sample {
+	labels := make([]kpis.Label, 0, len(metric.GetLabel()))
+	for _, label := range metric.GetLabel() {
+		labels = append(labels, kpis.Label{Name: label.GetName(), Value: label.GetValue()})
+	}
+
+	base := kpis.Sample{
+		Name:   metricFamily.GetName(),
+		Help:   metricFamily.GetHelp(),
+		Labels: labels,
+	}
+	if metric.TimestampMs != nil {
+		base.Timestamp = time.Unix(0, metric.GetTimestampMs()*int64(time.Millisecond))
+	}
+
+	// sample returns a copy of base, with the suffix, extra label and
+	// value given.
+	sample := func(typ kpis.SampleType, suffix string, extra *kpis.Label, value float64) kpis.Sample {
+		s := base
+		s.Type = typ
+		s.Suffix = suffix
+		s.Value = value
+		if extra != nil {
+			s.Labels = append(append([]kpis.Label{}, labels...), *extra)
+			sort.Slice(s.Labels, func(i, j int) bool {
+				return s.Labels[i].Name < s.Labels[j].Name
+			})
+		}
+		return s
+	}
+
+	switch metricFamily.GetType() {
+	case dto.MetricType_COUNTER:
+		return []kpis.Sample{sample(kpis.SampleCounter, "", nil, metric.GetCounter().GetValue())}
+
+	case dto.MetricType_GAUGE:
+		return []kpis.Sample{sample(kpis.SampleGauge, "", nil, metric.GetGauge().GetValue())}
+
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		samples := []kpis.Sample{}
+		for _, q := range summary.GetQuantile() {
+			quantile := kpis.Label{Name: "quantile", Value: strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64)}
+			samples = append(samples, sample(kpis.SampleSummary, "", &quantile, q.GetValue()))
+		}
+		return append(samples,
+			sample(kpis.SampleSummary, "_sum", nil, summary.GetSampleSum()),
+			sample(kpis.SampleSummary, "_count", nil, float64(summary.GetSampleCount())))
+
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		samples := []kpis.Sample{}
+		for _, b := range histogram.GetBucket() {
+			le := kpis.Label{Name: "le", Value: strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)}
+			samples = append(samples, sample(kpis.SampleHistogram, "_bucket", &le, float64(b.GetCumulativeCount())))
+		}
+		inf := kpis.Label{Name: "le", Value: "+Inf"}
+		return append(samples,
+			sample(kpis.SampleHistogram, "_bucket", &inf, float64(histogram.GetSampleCount())),
+			sample(kpis.SampleHistogram, "_sum", nil, histogram.GetSampleSum()),
+			sample(kpis.SampleHistogram, "_count", nil, float64(histogram.GetSampleCount())))
+
+	default:
+		return []kpis.Sample{sample(kpis.SampleUnknown, "", nil, metric.GetUntyped().GetValue())}
+	}
+}
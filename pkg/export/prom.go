@@ -5,13 +5,34 @@
 package export
 
 import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/onosproject/onos-exporter/pkg/collect"
 	"github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
 	"github.com/onosproject/onos-lib-go/pkg/logging"
-	"github.com/onosproject/onos-lib-go/pkg/prom"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// scrapeTimeoutHeader is set by Prometheus in a scrape request
+	// with the timeout of the scrape in seconds.
+	scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+	// scrapeTimeoutOffset is left out of the scrape timeout, so
+	// the exporter can write its response before Prometheus gives up.
+	scrapeTimeoutOffset = 500 * time.Millisecond
+
+	// shutdownTimeout limits the wait for ongoing scrapes on Close.
+	shutdownTimeout = 5 * time.Second
+)
+
 var (
 	log = logging.GetLogger("export", "prom")
 
@@ -28,51 +49,163 @@ var (
 
 // CollectorsPrometheus defines a prometheus collector
 // for all collectors.
+// Each collector is kept by a collect.Poller, which is started
+// in background mode, and the KPIs it exports and their cardinality
+// limits by the collector name in filters and limits.
 type CollectorsPrometheus struct {
-	collectors []collect.Collector
+	pollers    []*collect.Poller
+	filters    map[string]kpiFilter
+	limits     map[string]kpiLimits
+	background bool
 }
 
-// Retrieve implements the method needed for a Collector interface
-// in a prometheus exporter. It retrieves all the kpis from
-// CollectorsPrometheus and pass them to the ch channel using the
-// prometheus.Metric format.
-// The function collect.KPIs performs the collection of each collector
-// list of KPIs, and aggregates them in onosKPIs var.
-func (c *CollectorsPrometheus) Retrieve(ch chan<- prometheus.Metric) error {
-	onosKPIs := collect.KPIs(c.collectors)
+// Retrieve retrieves all the kpis from CollectorsPrometheus as samples.
+// In background mode the latest snapshot of each collector is used,
+// even if stale, otherwise collect.Poll polls each collector with ctx
+// and the kpis of the collectors that failed are left out, unless
+// they returned partial results.
+// The status of the snapshot of each collector is retrieved as a kpi too,
+// so a collector that fails can be told apart from one without kpis.
+func (c *CollectorsPrometheus) Retrieve(ctx context.Context) []kpis.Sample {
+	var snapshots []collect.Snapshot
+	if c.background {
+		snapshots = collect.Snapshots(c.pollers)
+	} else {
+		snapshots = collect.Poll(ctx, c.pollers)
+	}
+
+	statusKPI := kpis.OnosExporterCollectors()
+	statusKPI.Collectors = make(map[string]kpis.CollectorStatus)
+
+	samples := []kpis.Sample{}
+	now := time.Now()
+	for _, snapshot := range snapshots {
+		status := collectorStatus(snapshot, now)
+
+		if snapshot.Err == nil || snapshot.Partial || c.background {
+			collectorKPIs := c.filters[snapshot.Collector].filter(snapshot.KPIs)
+			collectorSamples, dropped := kpiSamples(collectorKPIs, c.limits[snapshot.Collector])
+			samples = append(samples, collectorSamples...)
+			status.KPIs = float64(len(collectorKPIs))
+			status.Dropped = dropped
+		}
+		statusKPI.Collectors[snapshot.Collector] = status
+	}
+
+	statusSamples, _ := kpiSamples([]kpis.KPI{statusKPI}, kpiLimits{})
+	return append(samples, statusSamples...)
+}
+
+// kpiSamples returns the samples of the kpis, and the number of
+// series dropped by the limits of each kpis.LimitedKPI by its name.
+// The samples a kpi returns along with an error are kept too, so
+// a single series that is not valid does not drop the rest.
+func kpiSamples(onosKPIs []kpis.KPI, limits kpiLimits) ([]kpis.Sample, map[string]float64) {
+	samples := []kpis.Sample{}
+	dropped := map[string]float64{}
 
 	for _, kpi := range onosKPIs {
-		promMetrics, err := kpi.PrometheusFormat()
+		var kpiSamples []kpis.Sample
+		var err error
 
-		if err != nil {
-			log.Errorf("onos kpi prometheus format error %s", err)
+		if limitedKPI, ok := kpi.(kpis.LimitedKPI); ok {
+			var droppedSeries int
+			kpiSamples, droppedSeries, err = limitedKPI.LimitedSamples(limits.get(limitedKPI.Name()))
+			dropped[limitedKPI.Name()] += float64(droppedSeries)
 		} else {
-			for _, m := range promMetrics {
-				ch <- m
-			}
+			kpiSamples, err = kpi.Samples()
 		}
+
+		if err != nil {
+			log.Errorf("onos kpi samples error %s", err)
+		}
+		samples = append(samples, kpiSamples...)
+	}
+
+	return samples, dropped
+}
+
+// collectorStatus defines the status of the snapshot of a collector
+// at the time now.
+func collectorStatus(snapshot collect.Snapshot, now time.Time) kpis.CollectorStatus {
+	status := kpis.CollectorStatus{
+		Name:     snapshot.Collector,
+		Duration: snapshot.Duration.Seconds(),
+		Errors:   float64(snapshot.Errors),
+	}
+	if snapshot.Err == nil && !snapshot.Timestamp.IsZero() {
+		status.Up = 1
+	}
+	if staleness, ok := snapshot.Stale(now); ok {
+		status.Timestamp = float64(snapshot.Timestamp.UnixNano()) / float64(time.Second)
+		status.Staleness = staleness.Seconds()
 	}
 
-	return nil
+	return status
+}
+
+// Close closes all the collectors of CollectorsPrometheus.
+func (c *CollectorsPrometheus) Close() {
+	collect.Close(c.pollers)
 }
 
 // Defines the set of collector used to extract KPIs for
 // the prometheus exporter. Each collector implements the
-// prom.Collector interface behavior via the method Collect.
-func initCollectorsPrometheus(config Config) prom.Collector {
-	collectors := []collect.Collector{}
+// collect.Collector interface behavior via the method Collect.
+// In background mode, the poller of each collector is started.
+// A collector that could not be created is polled through
+// a placeholder that fails with its error, so it is reported down.
+func initCollectorsPrometheus(config Config) *CollectorsPrometheus {
+	pollers := []*collect.Poller{}
+	filters := make(map[string]kpiFilter)
+	limits := make(map[string]kpiLimits)
 
 	for _, collectorName := range collectorNames {
 		collectorConfig, ok := config.CollectorsConfigs[collectorName]
 
-		if ok {
-			collector, err := collect.CreateCollector(collectorName, collectorConfig.ServiceAddress)
+		if ok && !collectorConfig.Enabled {
+			log.Infof("%s not added to collectors, disabled", collectorName)
+		} else if ok {
+			tlsConfig := collectorConfig.TLS.orDefault(config.TLS)
+			filter := collectorConfig.kpiFilter(config.Detail)
+			collector, err := collect.CreateCollector(collectorName, collect.Options{
+				ServiceAddress: collectorConfig.ServiceAddress,
+				TLS: collect.TLSOptions{
+					CAPath:     tlsConfig.CAPath,
+					CertPath:   tlsConfig.CertPath,
+					KeyPath:    tlsConfig.KeyPath,
+					ServerName: tlsConfig.ServerName,
+				},
+				NoTLS:   collectorConfig.NoTLS,
+				Timeout: collectorConfig.Timeout,
+				Watch:   collectorConfig.Watch,
+				KPM: collect.KPMOptions{
+					NoValue:       collectorConfig.KPM.NoValue,
+					TimestampUnit: collectorConfig.KPM.TimestampUnit,
+					Metrics:       collectorConfig.KPM.kpimonMetrics(),
+					UESliceKeys:   collectorConfig.KPM.UESliceKeys,
+					MaxUESeries:   collectorConfig.KPM.MaxUESeries,
+				},
+				Topo: collect.TopoOptions{
+					Labels:  collectorConfig.Topo.Labels,
+					Aspects: collectorConfig.Topo.Aspects,
+				},
+				ConfigFile: collectorConfig.ConfigFile,
+				Exported:   filter.exported,
+			})
 
 			if err != nil {
-				log.Errorf("%s not added to collectors %s", collectorName, err)
-			} else {
-				collectors = append(collectors, collector)
+				log.Errorf("%s could not be created, reported down: %s", collectorName, err)
+				collector = collect.NewFailedCollector(collectorName, err)
+			}
+
+			poller := collect.NewPoller(collector, collectorConfig.Interval)
+			if config.Background {
+				poller.Start()
 			}
+			pollers = append(pollers, poller)
+			filters[collectorName] = filter
+			limits[collectorName] = collectorConfig.kpiLimits(config.Limits)
 
 		} else {
 			log.Errorf("%s not added to collectors no configuration provided", collectorName)
@@ -80,21 +213,138 @@ func initCollectorsPrometheus(config Config) prom.Collector {
 	}
 
 	return &CollectorsPrometheus{
-		collectors: collectors,
+		pollers:    pollers,
+		filters:    filters,
+		limits:     limits,
+		background: config.Background,
+	}
+}
+
+// scrapeContext derives from the request r a context limited by
+// the scrape timeout set by Prometheus in the scrapeTimeoutHeader,
+// minus scrapeTimeoutOffset, if any.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	header := r.Header.Get(scrapeTimeoutHeader)
+	if header == "" {
+		return context.WithCancel(r.Context())
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		log.Warnf("invalid %s header %s", scrapeTimeoutHeader, header)
+		return context.WithCancel(r.Context())
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > scrapeTimeoutOffset {
+		timeout -= scrapeTimeoutOffset
 	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// prometheusExporter realizes the Exporter interface for prometheus.
+// It serves the metrics of its collectors, retrieved on each request
+// to path, and the metrics of the prometheus default registry (e.g.,
+// golang metrics), in the format negotiated with the Accept header
+// of the request.
+type prometheusExporter struct {
+	path       string
+	collectors *CollectorsPrometheus
+	server     *http.Server
+}
+
+// ServeHTTP serves the metrics of a scrape request, gzipped if the
+// request accepts it.
+func (e *prometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	samples, err := gatherSamples(prometheus.DefaultGatherer)
+	if err != nil {
+		log.Errorf("error gathering default metrics %s", err)
+	}
+	samples = append(samples, e.collectors.Retrieve(ctx)...)
+
+	encoder := negotiateEncoder(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", encoder.ContentType())
+
+	var out io.Writer = w
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+
+	if err := encoder.Encode(out, samples); err != nil {
+		log.Errorf("error encoding metrics %s", err)
+	}
+}
+
+// acceptsGzip reports if the Accept-Encoding header of r accepts gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.SplitN(coding, ";", 2)[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// Run serves the exporter endpoint until it is closed, over HTTPS
+// if the server has a TLS config.
+func (e *prometheusExporter) Run() error {
+	var err error
+	if e.server.TLSConfig != nil {
+		log.Infof("Serving metrics at https://%s%s", e.server.Addr, e.path)
+		err = e.server.ListenAndServeTLS("", "")
+	} else {
+		log.Infof("Serving metrics at %s%s", e.server.Addr, e.path)
+		err = e.server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close shuts down the exporter endpoint and closes its collectors.
+func (e *prometheusExporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := e.server.Shutdown(ctx)
+	e.collectors.Close()
+	return err
 }
 
 // PrometheusExporter uses Config to create an instance of a
-// Prometheus exporter, registering all its collectors, which must
-// implement the interface method Retrieve.
-func PrometheusExporter(config Config) prom.Exporter {
-	exporter := prom.NewExporter(config.Path, config.Address)
+// Prometheus Exporter, initializing all its collectors.
+// The endpoint is secured as set in the Web field of Config.
+func PrometheusExporter(config Config) (Exporter, error) {
+	tlsConfig, err := config.Web.serverTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &prometheusExporter{
+		path: config.Path,
+	}
 
-	log.Info("Registering collector sdran")
-	err := exporter.RegisterCollector("sdran", initCollectorsPrometheus(config))
+	handler, err := newWebAuth(exporter, config.Web)
 	if err != nil {
-		log.Errorf("error registering collector sdran %s", err)
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(config.Path, handler)
+	exporter.server = &http.Server{
+		Addr:      config.Address,
+		Handler:   mux,
+		TLSConfig: tlsConfig,
 	}
 
-	return exporter
+	exporter.collectors = initCollectorsPrometheus(config)
+
+	return exporter, nil
 }
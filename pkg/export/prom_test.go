@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onosproject/onos-exporter/pkg/collect"
+	"github.com/onosproject/onos-exporter/pkg/config"
+	"github.com/onosproject/onos-exporter/pkg/kpis"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ServeHTTP(t *testing.T) {
+	exporter := &prometheusExporter{collectors: &CollectorsPrometheus{}}
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	w := httptest.NewRecorder()
+	exporter.ServeHTTP(w, r)
+	assert.Equal(t, openMetricsEncoder{}.ContentType(), w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE go_goroutines gauge\n")
+	assert.True(t, strings.HasSuffix(w.Body.String(), "# EOF\n"))
+
+	r = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	exporter.ServeHTTP(w, r)
+	assert.Equal(t, prometheusTextEncoder{}.ContentType(), w.Header().Get("Content-Type"))
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "# TYPE go_gc_duration_seconds summary\n")
+	assert.Contains(t, string(body), "go_gc_duration_seconds_count ")
+}
+
+// statusValues returns the values of the status samples named name by
+// collector.
+func statusValues(samples []kpis.Sample, name string) map[string]float64 {
+	values := map[string]float64{}
+	for _, sample := range samples {
+		if sample.Name != name {
+			continue
+		}
+		for _, label := range sample.Labels {
+			if label.Name == "collector" {
+				values[label.Value] = sample.Value
+			}
+		}
+	}
+	return values
+}
+
+func Test_FailedCollector(t *testing.T) {
+	cfg := DefaultConfig()
+	for name, colCfg := range cfg.CollectorsConfigs {
+		colCfg.Enabled = name == config.ONOSUENIB
+		if colCfg.Enabled {
+			colCfg.ConfigFile = "/nonexistent/onos-uenib.yaml"
+		}
+		cfg.CollectorsConfigs[name] = colCfg
+	}
+
+	collectors := initCollectorsPrometheus(cfg)
+	defer collectors.Close()
+
+	samples := collectors.Retrieve(context.Background())
+	assert.Equal(t, map[string]float64{config.ONOSUENIB: 0}, statusValues(samples, "onos_exporter_collector_up"))
+	assert.Equal(t, map[string]float64{config.ONOSUENIB: 1}, statusValues(samples, "onos_exporter_collector_errors_total"))
+}
+
+// topoCollector is a collector of a topo entity and its count.
+type topoCollector struct{}
+
+func (topoCollector) Collect(ctx context.Context) ([]kpis.KPI, error) {
+	entitiesKPI := kpis.OnosTopoEntities()
+	entitiesKPI.Entities = map[string]kpis.TopoEntity{"e2:1": {ID: "e2:1", Kind: "e2node"}}
+	countsKPI := kpis.OnosTopoEntityCounts()
+	countsKPI.Add("e2node")
+	return []kpis.KPI{entitiesKPI, countsKPI}, nil
+}
+
+func (topoCollector) Name() string { return config.ONOSTOPO }
+
+func (topoCollector) Close() error { return nil }
+
+func Test_CollectorKPIsCount(t *testing.T) {
+	collectors := &CollectorsPrometheus{
+		pollers: []*collect.Poller{collect.NewPoller(topoCollector{}, time.Hour)},
+		filters: map[string]kpiFilter{config.ONOSTOPO: CollectorConfig{}.kpiFilter("")},
+	}
+
+	samples := collectors.Retrieve(context.Background())
+	assert.Equal(t, map[string]float64{config.ONOSTOPO: 1}, statusValues(samples, "onos_exporter_collector_up"))
+	assert.Equal(t, map[string]float64{config.ONOSTOPO: 2}, statusValues(samples, "onos_exporter_collector_kpis"))
+
+	collectors.filters[config.ONOSTOPO] = CollectorConfig{Detail: string(kpis.DetailAggregate)}.kpiFilter("")
+	samples = collectors.Retrieve(context.Background())
+	assert.Equal(t, map[string]float64{config.ONOSTOPO: 1}, statusValues(samples, "onos_exporter_collector_kpis"))
+}
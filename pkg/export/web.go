@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// webRealm is the realm requested to clients without credentials.
+const webRealm = "onos-exporter"
+
+// tlsEnabled returns true if the exporter endpoint is served over HTTPS.
+func (w WebConfig) tlsEnabled() bool {
+	return w.CertPath != ""
+}
+
+// authEnabled returns true if the requests to the exporter endpoint
+// must carry credentials.
+func (w WebConfig) authEnabled() bool {
+	return w.Username != "" || w.TokenFile != ""
+}
+
+// validate returns the problems found in the WebConfig.
+func (w WebConfig) validate() []string {
+	errs := []string{}
+
+	if (w.CertPath == "") != (w.KeyPath == "") {
+		errs = append(errs, "certPath and keyPath must be set together")
+	}
+	if w.ClientCAPath != "" && w.CertPath == "" {
+		errs = append(errs, "clientCAPath requires certPath and keyPath")
+	}
+	if (w.Username == "") != (w.PasswordFile == "") {
+		errs = append(errs, "username and passwordFile must be set together")
+	}
+	for _, path := range []string{w.CertPath, w.KeyPath, w.ClientCAPath, w.PasswordFile, w.TokenFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Sprintf("could not access %s: %s", path, err))
+		}
+	}
+
+	return errs
+}
+
+// serverTLSConfig returns the tls.Config of the exporter endpoint,
+// which requires and verifies client certificates if ClientCAPath
+// is set, or nil if the endpoint is served over plain HTTP.
+func (w WebConfig) serverTLSConfig() (*tls.Config, error) {
+	if !w.tlsEnabled() {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(w.CertPath, w.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load web certificate %s: %s", w.CertPath, err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if w.ClientCAPath != "" {
+		pem, err := ioutil.ReadFile(w.ClientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read web client CA %s: %s", w.ClientCAPath, err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificate found in %s", w.ClientCAPath)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// readSecret reads a password or token from the file at path,
+// leaving out the surrounding spaces and newlines.
+func readSecret(path string) (string, error) {
+	secret, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %s", path, err)
+	}
+	s := strings.TrimSpace(string(secret))
+	if s == "" {
+		return "", fmt.Errorf("empty secret in %s", path)
+	}
+	return s, nil
+}
+
+// webAuth wraps an http.Handler, serving only the requests that carry
+// the basic auth credentials or the bearer token it was created with.
+// Either one is enough if both are set.
+type webAuth struct {
+	handler  http.Handler
+	username string
+	password string
+	token    string
+}
+
+// newWebAuth creates a webAuth of handler with the credentials
+// set in the WebConfig, or returns handler if none is set.
+func newWebAuth(handler http.Handler, w WebConfig) (http.Handler, error) {
+	if !w.authEnabled() {
+		return handler, nil
+	}
+
+	auth := &webAuth{
+		handler:  handler,
+		username: w.Username,
+	}
+
+	var err error
+	if w.PasswordFile != "" {
+		if auth.password, err = readSecret(w.PasswordFile); err != nil {
+			return nil, err
+		}
+	}
+	if w.TokenFile != "" {
+		if auth.token, err = readSecret(w.TokenFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth, nil
+}
+
+// authorized checks the credentials of the request r in constant time.
+func (a *webAuth) authorized(r *http.Request) bool {
+	if a.username != "" {
+		if username, password, ok := r.BasicAuth(); ok {
+			userOK := subtle.ConstantTimeCompare([]byte(username), []byte(a.username)) == 1
+			passOK := subtle.ConstantTimeCompare([]byte(password), []byte(a.password)) == 1
+			return userOK && passOK
+		}
+	}
+
+	if a.token != "" {
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(header, "Bearer ") {
+			token := strings.TrimPrefix(header, "Bearer ")
+			return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+		}
+	}
+
+	return false
+}
+
+// ServeHTTP serves the authorized requests, answering the others
+// with 401 Unauthorized.
+func (a *webAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		if a.username != "" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", webRealm))
+		} else {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", webRealm))
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	a.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package export
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WebAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	web := WebConfig{
+		Username:     "prometheus",
+		PasswordFile: writeConfig(t, "password", "secret\n"),
+		TokenFile:    writeConfig(t, "token", "t0ken"),
+	}
+	assert.Empty(t, web.validate())
+
+	handler, err := newWebAuth(ok, web)
+	assert.NoError(t, err)
+
+	status := func(setAuth func(r *http.Request)) int {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		setAuth(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status(func(r *http.Request) { r.SetBasicAuth("prometheus", "secret") }))
+	assert.Equal(t, http.StatusOK, status(func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }))
+	assert.Equal(t, http.StatusUnauthorized, status(func(r *http.Request) { r.SetBasicAuth("prometheus", "t0ken") }))
+	assert.Equal(t, http.StatusUnauthorized, status(func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }))
+	assert.Equal(t, http.StatusUnauthorized, status(func(r *http.Request) {}))
+
+	handler, err = newWebAuth(ok, WebConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status(func(r *http.Request) {}))
+
+	assert.Len(t, WebConfig{Username: "prometheus", ClientCAPath: "/no/ca.crt"}.validate(), 3)
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ObjectCount defines the number of objects with the same LabelValues.
+type ObjectCount struct {
+	LabelValues []string
+	Count       float64
+}
+
+// objectCounts is the kpi of the aggregate of the objects of a detail
+// kpi (e.g., the topo entities by kind). Counts stores the number of
+// objects by their values of Labels, exported as a gauge named after
+// the kpi, so the objects can be counted without a series per object.
+type objectCounts struct {
+	kpiBase
+	builder      *builder
+	staticLabels map[string]string
+	Labels       []string
+	LabelValues  []string
+	Counts       map[string]*ObjectCount
+}
+
+func newObjectCounts(name, description string, builder *builder, staticLabels map[string]string, labels ...string) *objectCounts {
+	kpi := &objectCounts{
+		builder:      builder,
+		staticLabels: staticLabels,
+		Labels:       labels,
+		Counts:       make(map[string]*ObjectCount),
+	}
+	kpi.kpiBase = newKPIBase(name, description, kpi.LimitedSamples)
+	return kpi
+}
+
+// Add counts an object with labelValues, in the order of Labels.
+func (c *objectCounts) Add(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	count, ok := c.Counts[key]
+	if !ok {
+		count = &ObjectCount{LabelValues: labelValues}
+		c.Counts[key] = count
+	}
+	count.Count++
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for objectCounts.
+func (c *objectCounts) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	metricDesc := c.builder.NewMetricDesc(c.name, c.description, c.Labels, c.staticLabels)
+
+	for _, count := range c.Counts {
+		l.add(
+			metricDesc,
+			prometheus.GaugeValue,
+			count.Count,
+			count.LabelValues...,
+		)
+	}
+
+	return l.samples()
+}
@@ -0,0 +1,29 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ObjectCounts(t *testing.T) {
+	kpi := OnosTopoSliceCounts()
+	kpi.Add("SLICE_TYPE_DL_SLICE", "SCHEDULER_TYPE_ROUND_ROBIN")
+	kpi.Add("SLICE_TYPE_DL_SLICE", "SCHEDULER_TYPE_ROUND_ROBIN")
+	kpi.Add("SLICE_TYPE_UL_SLICE", "SCHEDULER_TYPE_ROUND_ROBIN")
+
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+
+	total := 0.0
+	for _, sample := range samples {
+		total += sample.Value
+	}
+	assert.Equal(t, 3.0, total)
+	assert.Equal(t, "slices_count", kpi.Name())
+}
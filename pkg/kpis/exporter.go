@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Var definitions of onos exporter metrics builder and static labels.
+// builder is used to create the metric descs of the samples.
+var (
+	staticLabelsExporter = map[string]string{"sdran": "exporter"}
+	onosExporterBuilder  = newBuilder("onos", "exporter")
+)
+
+// CollectorStatus defines the state of the latest KPIs of a collector.
+// Up is 1 if the latest retrieval of KPIs by the collector succeeded,
+// 0 otherwise, Duration is the duration of that retrieval in seconds,
+// KPIs the number of KPIs exported from the collector, and
+// Errors the number of failed retrievals so far.
+// Timestamp is the unix time (in seconds) of the last successful
+// retrieval of KPIs by the collector, zero if it never succeeded, and
+// Staleness the number of seconds elapsed since then.
+// Dropped is the number of series of each KPI of the collector
+// dropped by its cardinality limits, by KPI name.
+type CollectorStatus struct {
+	Name      string
+	Up        float64
+	Duration  float64
+	KPIs      float64
+	Errors    float64
+	Timestamp float64
+	Staleness float64
+	Dropped   map[string]float64
+}
+
+// onosExporterCollectors is the kpi of the self-metrics of the
+// exporter. Collectors stores the status of each collector of the
+// exporter, exported as a series per collector.
+type onosExporterCollectors struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Collectors  map[string]CollectorStatus
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for onosExporterCollectors.
+func (c *onosExporterCollectors) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	c.Labels = []string{"collector"}
+	upDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_up",
+		"Whether the latest retrieval of KPIs by the collector succeeded",
+		c.Labels, staticLabelsExporter)
+	durationDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_duration_seconds",
+		"The duration of the latest retrieval of KPIs by the collector",
+		c.Labels, staticLabelsExporter)
+	kpisDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_kpis",
+		"The number of KPIs exported from the collector",
+		c.Labels, staticLabelsExporter)
+	errorsDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_errors_total",
+		"The number of failed retrievals of KPIs by the collector",
+		c.Labels, staticLabelsExporter)
+	timestampDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_last_success_timestamp_seconds",
+		"The unix time of the last successful retrieval of KPIs by the collector",
+		c.Labels, staticLabelsExporter)
+	stalenessDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_staleness_seconds",
+		"The seconds elapsed since the last successful retrieval of KPIs by the collector",
+		c.Labels, staticLabelsExporter)
+	droppedDesc := onosExporterBuilder.NewMetricDesc(
+		c.name+"_series_dropped",
+		"The number of series of the KPI of the collector dropped by its cardinality limits",
+		[]string{"collector", "kpi"}, staticLabelsExporter)
+
+	for _, col := range c.Collectors {
+		l.add(upDesc, prometheus.GaugeValue, col.Up, col.Name)
+		l.add(durationDesc, prometheus.GaugeValue, col.Duration, col.Name)
+		l.add(kpisDesc, prometheus.GaugeValue, col.KPIs, col.Name)
+		l.add(errorsDesc, prometheus.CounterValue, col.Errors, col.Name)
+		l.add(timestampDesc, prometheus.GaugeValue, col.Timestamp, col.Name)
+
+		for kpiName, dropped := range col.Dropped {
+			l.add(droppedDesc, prometheus.GaugeValue, dropped, col.Name, kpiName)
+		}
+
+		if col.Timestamp == 0 {
+			continue
+		}
+
+		l.add(stalenessDesc, prometheus.GaugeValue, col.Staleness, col.Name)
+	}
+
+	return l.samples()
+}
@@ -9,9 +9,87 @@ import "github.com/prometheus/client_golang/prometheus"
 // KPI interface defines the methods that format the behavior
 // of a kpi. It includes that a kpi must provide those methods
 // in order to support its content to be exported to a particular
-// TSDB.
+// TSDB. Samples returns the kpi as a list of Sample, independent of
+// the format it is exported in, which the encoders of the exporter
+// encode (e.g., in the Prometheus text format).
+// PrometheusFormat returns the Samples as prometheus metrics, for the
+// users of the kpis that collect them with the prometheus client.
 type KPI interface {
 	PrometheusFormat() ([]prometheus.Metric, error)
+	Samples() ([]Sample, error)
+}
+
+// kpiBase is embedded in each kpi, holding its name and description.
+// Its Samples are the LimitedSamples of the kpi without limits, so
+// a kpi only implements LimitedSamples, passed to newKPIBase by its
+// factory.
+type kpiBase struct {
+	name           string
+	description    string
+	limitedSamples func(limits Limits) ([]Sample, int, error)
+}
+
+func newKPIBase(name, description string, limitedSamples func(limits Limits) ([]Sample, int, error)) kpiBase {
+	return kpiBase{
+		name:           name,
+		description:    description,
+		limitedSamples: limitedSamples,
+	}
+}
+
+// Name implements the contract behavior of the kpis.LimitedKPI
+// interface for the kpi.
+func (k *kpiBase) Name() string {
+	return k.name
+}
+
+// Description returns the description of the kpi.
+func (k *kpiBase) Description() string {
+	return k.description
+}
+
+// Samples implements the contract behavior of the kpis.KPI
+// interface for the kpi.
+func (k *kpiBase) Samples() ([]Sample, error) {
+	samples, _, err := k.limitedSamples(Limits{})
+	return samples, err
+}
+
+// PrometheusFormat implements the contract behavior of the kpis.KPI
+// interface for the kpi, with its Samples as prometheus metrics (e.g.,
+// for a prometheus.Collector). The metrics that could be formatted
+// are returned along with an error.
+func (k *kpiBase) PrometheusFormat() ([]prometheus.Metric, error) {
+	samples, err := k.Samples()
+	metrics, metricsErr := PrometheusMetrics(samples)
+	if err == nil {
+		err = metricsErr
+	}
+	return metrics, err
+}
+
+// DetailLevel defines the level of detail of the KPIs exported.
+type DetailLevel string
+
+// Const definitions of the detail levels.
+const (
+	// DetailObject exports the KPIs with a series per object (e.g.,
+	// per topo entity), along with their aggregates.
+	DetailObject DetailLevel = "object"
+	// DetailAggregate exports only the aggregates of the objects
+	// (e.g., the number of topo entities by kind).
+	DetailAggregate DetailLevel = "aggregate"
+)
+
+// ObjectKPI reports if kpi has a series per object, which is left out
+// at DetailAggregate.
+func ObjectKPI(kpi KPI) bool {
+	switch kpi.(type) {
+	case *onosE2tSubscriptions, *onosE2tChannels, *xappPciHistory, *xappPciNeighbors, *topoEntities, *topoRelations, *topoSlices, *topoAspects, *topoRAN, *topoE2Controls, *onosUenibUEs:
+		return true
+	default:
+		return false
+	}
 }
 
 // Const definitions of kpis name and description.
@@ -20,12 +98,30 @@ const (
 	onosE2tConnectionsKPIName        = "subscriptions"
 	onosE2tConnectionsKPIDescription = "The e2t subscriptions"
 
+	onosE2tSubscriptionCountsKPIName        = "subscriptions_count"
+	onosE2tSubscriptionCountsKPIDescription = "The number of e2t subscriptions by service model, status phase and state"
+
+	onosE2tChannelsKPIName        = "channels"
+	onosE2tChannelsKPIDescription = "The e2t channels"
+
+	onosE2tChannelCountsKPIName        = "channels_count"
+	onosE2tChannelCountsKPIDescription = "The number of e2t channels by app, E2 node, status phase and state"
+
+	onosE2tSubscriptionLifecycleKPIName        = "subscriptions_lifecycle"
+	onosE2tSubscriptionLifecycleKPIDescription = "The number of e2t subscriptions created, deleted and failed"
+
 	xappPciNumConflictsKPIName     = "info"
 	xappPciNumConflictsDescription = "The xapp pci cell info"
 
 	xappPciResolvedConflictsKPIName     = "conflicts"
 	xappPciResolvedConflictsDescription = "The xapp pci resolved cell conflicts"
 
+	xappPciHistoryKPIName        = "history"
+	xappPciHistoryKPIDescription = "The xapp pci conflicts and PCI changes per cell"
+
+	xappPciNeighborsKPIName        = "neighbors"
+	xappPciNeighborsKPIDescription = "The xapp pci neighbor relations of the cells"
+
 	xappkpimonKPIName     = "kpm"
 	xappkpimonDescription = "The KPM related metrics"
 
@@ -38,90 +134,243 @@ const (
 	topoSlicesKPIName        = "slices"
 	topoSlicesKPIDescription = "The onos topo slices"
 
+	topoEntityCountsKPIName        = "entities_count"
+	topoEntityCountsKPIDescription = "The number of onos topo entities by kind"
+
+	topoRelationCountsKPIName        = "relations_count"
+	topoRelationCountsKPIDescription = "The number of onos topo relations by kind"
+
+	topoSliceCountsKPIName        = "slices_count"
+	topoSliceCountsKPIDescription = "The number of onos topo slices by slice type and scheduler type"
+
+	topoRANKPIName        = "ran"
+	topoRANKPIDescription = "The RAN data of the onos topo entities"
+
+	topoE2ControlsKPIName        = "controls"
+	topoE2ControlsKPIDescription = "The E2 control relations and the mastership of the E2 nodes"
+
+	topoAspectsKPIName        = "aspects"
+	topoAspectsKPIDescription = "The onos topo aspects"
+
 	OnosUenibUEsKPIName        = "ues"
 	OnosUenibUEsKPIDescription = "The uenib ues"
 
+	onosUenibUECountsKPIName        = "ues_count"
+	onosUenibUECountsKPIDescription = "The number of uenib ues by aspect type"
+
+	onosUenibUEChangesKPIName        = "ue"
+	onosUenibUEChangesKPIDescription = "The changes of the uenib ues"
+
 	onosProfileKPIName        = "pprof"
 	onosProfileKPIDescription = "The onos profile"
+
+	onosExporterCollectorsKPIName        = "collector"
+	onosExporterCollectorsKPIDescription = "The onos exporter collectors"
 )
 
 // OnosE2tSubscriptions defines the factory implementation of a kpi
 // onosE2tSubscriptions having a well defined name and description.
 func OnosE2tSubscriptions() *onosE2tSubscriptions {
-	return &onosE2tSubscriptions{
-		name:        onosE2tConnectionsKPIName,
-		description: onosE2tConnectionsKPIDescription,
-	}
+	kpi := &onosE2tSubscriptions{}
+	kpi.kpiBase = newKPIBase(onosE2tConnectionsKPIName, onosE2tConnectionsKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosE2tSubscriptionLifecycle defines the factory implementation of
+// a kpi onosE2tSubscriptionLifecycle having a well defined name and
+// description.
+func OnosE2tSubscriptionLifecycle() *onosE2tSubscriptionLifecycle {
+	kpi := &onosE2tSubscriptionLifecycle{}
+	kpi.kpiBase = newKPIBase(onosE2tSubscriptionLifecycleKPIName, onosE2tSubscriptionLifecycleKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosE2tSubscriptionCounts defines the factory implementation of
+// a kpi objectCounts of the e2t subscriptions by service model, status
+// phase and state.
+func OnosE2tSubscriptionCounts() *objectCounts {
+	return newObjectCounts(
+		onosE2tSubscriptionCountsKPIName,
+		onosE2tSubscriptionCountsKPIDescription,
+		onose2tBuilder, staticLabelsE2t,
+		"service_model_name", "service_model_version", "status_phase", "status_state")
+}
+
+// OnosE2tChannels defines the factory implementation of a kpi
+// onosE2tChannels having a well defined name and description.
+func OnosE2tChannels() *onosE2tChannels {
+	kpi := &onosE2tChannels{}
+	kpi.kpiBase = newKPIBase(onosE2tChannelsKPIName, onosE2tChannelsKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosE2tChannelCounts defines the factory implementation of a kpi
+// objectCounts of the e2t channels by app, E2 node, status phase and
+// state.
+func OnosE2tChannelCounts() *objectCounts {
+	return newObjectCounts(
+		onosE2tChannelCountsKPIName,
+		onosE2tChannelCountsKPIDescription,
+		onose2tBuilder, staticLabelsE2t,
+		"app_id", "node_id", "status_phase", "status_state")
 }
 
 // XappKpiMon defines the factory implementation of a kpi
 // onosE2subs having a well defined name and description.
 func XappKpiMon() *xappkpimon {
-	return &xappkpimon{
-		name:        xappkpimonKPIName,
-		description: xappkpimonDescription,
-	}
+	kpi := &xappkpimon{}
+	kpi.kpiBase = newKPIBase(xappkpimonKPIName, xappkpimonDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // XappPciNumConflicts defines the factory implementation of a kpi
 // xappPciNumConflicts having a well defined name and description.
 func XappPciNumConflicts() *xappPciNumConflicts {
-	return &xappPciNumConflicts{
-		name:        xappPciNumConflictsKPIName,
-		description: xappPciNumConflictsDescription,
-	}
+	kpi := &xappPciNumConflicts{}
+	kpi.kpiBase = newKPIBase(xappPciNumConflictsKPIName, xappPciNumConflictsDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // XappPciResolvedConflicts defines the factory implementation of a kpi
 // xappPciResolvedConflicts having a well defined name and description.
 func XappPciResolvedConflicts() *xappPciResolvedConflicts {
-	return &xappPciResolvedConflicts{
-		name:        xappPciResolvedConflictsKPIName,
-		description: xappPciResolvedConflictsDescription,
-	}
+	kpi := &xappPciResolvedConflicts{}
+	kpi.kpiBase = newKPIBase(xappPciResolvedConflictsKPIName, xappPciResolvedConflictsDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// XappPciHistory defines the factory implementation of a kpi
+// xappPciHistory having a well defined name and description.
+func XappPciHistory() *xappPciHistory {
+	kpi := &xappPciHistory{}
+	kpi.kpiBase = newKPIBase(xappPciHistoryKPIName, xappPciHistoryKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// XappPciNeighbors defines the factory implementation of a kpi
+// xappPciNeighbors having a well defined name and description.
+func XappPciNeighbors() *xappPciNeighbors {
+	kpi := &xappPciNeighbors{}
+	kpi.kpiBase = newKPIBase(xappPciNeighborsKPIName, xappPciNeighborsKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // OnosTopoEntities defines the factory implementation of a kpi
 // topoEntities having a well defined name and description.
 func OnosTopoEntities() *topoEntities {
-	return &topoEntities{
-		name:        topoEntitiesKPIName,
-		description: topoEntitiesKPIDescription,
-	}
+	kpi := &topoEntities{}
+	kpi.kpiBase = newKPIBase(topoEntitiesKPIName, topoEntitiesKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // OnosTopoRelations defines the factory implementation of a kpi
 // topoRelations having a well defined name and description.
 func OnosTopoRelations() *topoRelations {
-	return &topoRelations{
-		name:        topoRelationsKPIName,
-		description: topoRelationsKPIDescription,
-	}
+	kpi := &topoRelations{}
+	kpi.kpiBase = newKPIBase(topoRelationsKPIName, topoRelationsKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // OnosTopoSlices defines the factory implementation of a kpi
 // topoSlices having a well defined name and description.
 func OnosTopoSlices() *topoSlices {
-	return &topoSlices{
-		name:        topoSlicesKPIName,
-		description: topoSlicesKPIDescription,
-	}
+	kpi := &topoSlices{}
+	kpi.kpiBase = newKPIBase(topoSlicesKPIName, topoSlicesKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosTopoEntityCounts defines the factory implementation of a kpi
+// objectCounts of the topo entities by kind.
+func OnosTopoEntityCounts() *objectCounts {
+	return newObjectCounts(
+		topoEntityCountsKPIName,
+		topoEntityCountsKPIDescription,
+		onosTopoBuilder, staticLabelsOnosTopo,
+		"kind")
+}
+
+// OnosTopoRelationCounts defines the factory implementation of a kpi
+// objectCounts of the topo relations by kind.
+func OnosTopoRelationCounts() *objectCounts {
+	return newObjectCounts(
+		topoRelationCountsKPIName,
+		topoRelationCountsKPIDescription,
+		onosTopoBuilder, staticLabelsOnosTopo,
+		"kind")
+}
+
+// OnosTopoSliceCounts defines the factory implementation of a kpi
+// objectCounts of the topo slices by slice type and scheduler type.
+func OnosTopoSliceCounts() *objectCounts {
+	return newObjectCounts(
+		topoSliceCountsKPIName,
+		topoSliceCountsKPIDescription,
+		onosTopoBuilder, staticLabelsOnosTopo,
+		"slice_type", "scheduler_type")
+}
+
+// OnosTopoRAN defines the factory implementation of a kpi
+// topoRAN having a well defined name and description.
+func OnosTopoRAN() *topoRAN {
+	kpi := &topoRAN{}
+	kpi.kpiBase = newKPIBase(topoRANKPIName, topoRANKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosTopoE2Controls defines the factory implementation of a kpi
+// topoE2Controls having a well defined name and description.
+func OnosTopoE2Controls() *topoE2Controls {
+	kpi := &topoE2Controls{}
+	kpi.kpiBase = newKPIBase(topoE2ControlsKPIName, topoE2ControlsKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosTopoAspects defines the factory implementation of a kpi
+// topoAspects having a well defined name and description.
+func OnosTopoAspects() *topoAspects {
+	kpi := &topoAspects{}
+	kpi.kpiBase = newKPIBase(topoAspectsKPIName, topoAspectsKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // OnosUenibUEs defines the factory implementation of a kpi
 // onosUenibUEs having a well defined name and description.
 func OnosUenibUEs() *onosUenibUEs {
-	return &onosUenibUEs{
-		name:        OnosUenibUEsKPIName,
-		description: OnosUenibUEsKPIDescription,
-	}
+	kpi := &onosUenibUEs{}
+	kpi.kpiBase = newKPIBase(OnosUenibUEsKPIName, OnosUenibUEsKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosUenibUECounts defines the factory implementation of a kpi
+// objectCounts of the uenib UEs by aspect type.
+func OnosUenibUECounts() *objectCounts {
+	return newObjectCounts(
+		onosUenibUECountsKPIName,
+		onosUenibUECountsKPIDescription,
+		onosUenibBuilder, staticLabelsOnosUenib,
+		"aspect_type")
+}
+
+// OnosUenibUEChanges defines the factory implementation of a kpi
+// onosUenibUEChanges having a well defined name and description.
+func OnosUenibUEChanges() *onosUenibUEChanges {
+	kpi := &onosUenibUEChanges{}
+	kpi.kpiBase = newKPIBase(onosUenibUEChangesKPIName, onosUenibUEChangesKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
 
 // OnosProfileHeap defines the factory implementation of a kpi
 // onosProfileHeap having a well defined name and description.
 func OnosProfileHeap() *onosProfileHeap {
-	return &onosProfileHeap{
-		name:        onosProfileKPIName,
-		description: onosProfileKPIDescription,
-	}
+	kpi := &onosProfileHeap{}
+	kpi.kpiBase = newKPIBase(onosProfileKPIName, onosProfileKPIDescription, kpi.LimitedSamples)
+	return kpi
+}
+
+// OnosExporterCollectors defines the factory implementation of a kpi
+// onosExporterCollectors having a well defined name and description.
+func OnosExporterCollectors() *onosExporterCollectors {
+	kpi := &onosExporterCollectors{}
+	kpi.kpiBase = newKPIBase(onosExporterCollectorsKPIName, onosExporterCollectorsKPIDescription, kpi.LimitedSamples)
+	return kpi
 }
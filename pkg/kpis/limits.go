@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LimitPolicy defines what happens to a series with a label value
+// longer than the maximum label length.
+type LimitPolicy string
+
+// Const definitions of the limit policies.
+const (
+	// LimitTruncate truncates the label values to the maximum length.
+	LimitTruncate LimitPolicy = "truncate"
+	// LimitDrop drops the series.
+	LimitDrop LimitPolicy = "drop"
+)
+
+// Limits defines the cardinality limits of the metrics of a KPI.
+// MaxLabelLength is the maximum length (in bytes) of a label value,
+// and MaxSeries the maximum number of series of each metric, no limit
+// if zero. Policy is applied to the series with a label value longer
+// than MaxLabelLength, LimitTruncate if not set. The series beyond
+// MaxSeries are dropped, keeping the first ones in order of their
+// label values, so the same series are kept on every scrape.
+type Limits struct {
+	MaxLabelLength int
+	MaxSeries      int
+	Policy         LimitPolicy
+}
+
+// LimitedKPI is implemented by the KPIs whose metrics are subject to
+// cardinality Limits. LimitedSamples returns the samples of the KPI
+// like Samples, with the limits applied, returning the number of
+// series dropped by them too. Name returns the name of the KPI.
+type LimitedKPI interface {
+	KPI
+	Name() string
+	LimitedSamples(limits Limits) ([]Sample, int, error)
+}
+
+// series defines a series of a metric, before it is limited.
+type series struct {
+	valueType   prometheus.ValueType
+	value       float64
+	labelValues []string
+	timestamp   time.Time
+	key         string
+}
+
+// limiter collects the series of the metrics of a KPI, applying
+// Limits to them when their samples are built.
+// The series are kept by the string of their desc, so the series of
+// equal descs built apart are limited as the same metric.
+type limiter struct {
+	limits  Limits
+	descs   []*metricDesc
+	series  map[string][]series
+	dropped int
+}
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{
+		limits: limits,
+		series: make(map[string][]series),
+	}
+}
+
+// add adds a series of the metric desc.
+func (l *limiter) add(desc *metricDesc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	l.addWithTimestamp(desc, time.Time{}, valueType, value, labelValues...)
+}
+
+// addWithTimestamp adds a series of the metric desc with timestamp,
+// the series has no timestamp if it is zero.
+func (l *limiter) addWithTimestamp(desc *metricDesc, timestamp time.Time, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	if max := l.limits.MaxLabelLength; max > 0 {
+		truncated := false
+		for i, v := range labelValues {
+			if len(v) <= max {
+				continue
+			}
+			if l.limits.Policy == LimitDrop {
+				l.dropped++
+				return
+			}
+			if !truncated {
+				labelValues = append([]string{}, labelValues...)
+				truncated = true
+			}
+			labelValues[i] = truncate(v, max)
+		}
+	}
+
+	descKey := desc.String()
+	if _, ok := l.series[descKey]; !ok {
+		l.descs = append(l.descs, desc)
+	}
+	l.series[descKey] = append(l.series[descKey], series{
+		valueType:   valueType,
+		value:       value,
+		labelValues: labelValues,
+		timestamp:   timestamp,
+		key:         strings.Join(labelValues, "\xff"),
+	})
+}
+
+// truncate truncates s to at most max bytes, without splitting a rune.
+func truncate(s string, max int) string {
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// samples builds the samples of the series added, returning them along
+// with the number of series dropped. The series that became duplicated
+// after truncating their label values are dropped too.
+// A series added with different timestamps (e.g., the KPM records of a
+// measurement) has a sample of each timestamp, in time order, and is
+// counted once against MaxSeries.
+// A series that is not valid is left out, and reported in the error
+// returned along with the other samples.
+func (l *limiter) samples() ([]Sample, int, error) {
+	samples := []Sample{}
+	failed := 0
+	var lastErr error
+
+	for _, desc := range l.descs {
+		descSeries := l.series[desc.String()]
+		sort.SliceStable(descSeries, func(i, j int) bool {
+			if descSeries[i].key != descSeries[j].key {
+				return descSeries[i].key < descSeries[j].key
+			}
+			return descSeries[i].timestamp.Before(descSeries[j].timestamp)
+		})
+
+		count := 0
+		capped, counted := false, false
+		for i, s := range descSeries {
+			newSeries := i == 0 || s.key != descSeries[i-1].key
+			if !newSeries && s.timestamp.Equal(descSeries[i-1].timestamp) {
+				l.dropped++
+				continue
+			}
+			if newSeries {
+				capped = l.limits.MaxSeries > 0 && count >= l.limits.MaxSeries
+				counted = false
+				if capped {
+					l.dropped++
+				}
+			}
+			if capped {
+				continue
+			}
+
+			if err := desc.validate(s.valueType, s.labelValues); err != nil {
+				failed++
+				lastErr = err
+				continue
+			}
+			samples = append(samples, desc.sample(s.valueType, s.value, s.timestamp, s.labelValues))
+			if !counted {
+				count++
+				counted = true
+			}
+		}
+	}
+
+	if failed > 0 {
+		return samples, l.dropped, fmt.Errorf("%d series not exported, last error: %s", failed, lastErr)
+	}
+	return samples, l.dropped, nil
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Truncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+	assert.Equal(t, "a", truncate("aé", 2))
+}
+
+func Test_LimitedSamples(t *testing.T) {
+	kpi := OnosTopoSlices()
+	kpi.Slices = map[string]TopoEntitySlice{
+		"1": {NodeID: "e2:1", SliceID: "1", UeIdList: "1,2,3,4"},
+		"2": {NodeID: "e2:1", SliceID: "2", UeIdList: "5,6,7,8,9"},
+		"3": {NodeID: "e2:1", SliceID: "3", UeIdList: "10"},
+	}
+
+	samples, dropped, err := kpi.LimitedSamples(Limits{})
+	assert.NoError(t, err)
+	assert.Len(t, samples, 3)
+	assert.Equal(t, 0, dropped)
+
+	samples, dropped, err = kpi.LimitedSamples(Limits{MaxLabelLength: 7, Policy: LimitDrop})
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, 1, dropped)
+
+	samples, dropped, err = kpi.LimitedSamples(Limits{MaxLabelLength: 7, MaxSeries: 2})
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, 1, dropped)
+
+	// Series that are equal once truncated are dropped.
+	kpi.Slices["3"] = TopoEntitySlice{NodeID: "e2:1", SliceID: "1", UeIdList: "1,2,3,4,5"}
+	samples, dropped, err = kpi.LimitedSamples(Limits{MaxLabelLength: 7})
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, 1, dropped)
+}
@@ -5,55 +5,120 @@
 package kpis
 
 import (
-	"github.com/onosproject/onos-lib-go/pkg/prom"
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of e2t metrics onose2tBuilder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsE2t = map[string]string{"sdran": "e2t"}
-	onose2tBuilder  = prom.NewBuilder("onos", "e2t", staticLabelsE2t)
+	onose2tBuilder  = newBuilder("onos", "e2t")
 )
 
+// E2tSubscription defines an e2t subscription. Channels is the number
+// of channels of the subscription, Age the seconds since it was first
+// seen, and NotOpen the seconds since it left the OPEN phase, zero
+// while it is OPEN.
 type E2tSubscription struct {
 	Id                  string
-	Revision            string
+	Revision            uint64
 	ServiceModelName    string
 	ServiceModelVersion string
 	E2NodeID            string
 	Encoding            string
 	StatusPhase         string
 	StatusState         string
+	Channels            int
+	Age                 float64
+	NotOpen             float64
+}
+
+// E2tChannel defines an e2t channel, opened by an instance of an app
+// for the subscription SubscriptionID.
+type E2tChannel struct {
+	Id               string
+	AppID            string
+	AppInstanceID    string
+	E2NodeID         string
+	SubscriptionID   string
+	ServiceModelName string
+	StatusPhase      string
+	StatusState      string
+}
+
+// onosE2tChannels is the kpi of the channels opened by the apps on
+// e2t. Channels stores each channel by its id, exported as a series
+// of value 1 labeled with its app, E2 node and subscription.
+type onosE2tChannels struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Channels    map[string]E2tChannel
 }
 
-// onosE2tSubscriptions defines the common data that can be used
-// to output the format of a KPI (e.g., PrometheusFormat).
-// Subs stores each data structure for a subsctiption
-// which contains the annotations as defined by E2tSubscription struct.
+// onosE2tSubscriptionLifecycle is the kpi of the churn of the e2t
+// subscriptions. Created, Deleted and Failed store the number of
+// subscriptions created, deleted and failed per service model name,
+// exported as counters.
+type onosE2tSubscriptionLifecycle struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Created     map[string]uint64
+	Deleted     map[string]uint64
+	Failed      map[string]uint64
+}
+
+// onosE2tSubscriptions is the kpi of the e2t subscriptions.
+// Subs stores each subscription by its id, with the annotations
+// defined by E2tSubscription, exported as a series of value 1 along
+// with the revision, age and channels of the subscription.
+// The revision label of the series is deprecated in favour of the
+// subscription_revision gauge, and is removed in the next release.
 type onosE2tSubscriptions struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	Subs        map[string]E2tSubscription
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for onosE2tSubscriptions.
-func (c *onosE2tSubscriptions) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (c *onosE2tSubscriptions) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
 	c.Labels = []string{"id", "revision", "service_model_name", "service_model_version", "node_id", "encoding", "status_phase", "status_state"}
 	metricDesc := onose2tBuilder.NewMetricDesc(c.name, c.description, c.Labels, staticLabelsE2t)
+	revisionDesc := onose2tBuilder.NewMetricDesc(
+		"subscription_revision",
+		"The revision of the e2t subscription",
+		[]string{"id"}, staticLabelsE2t)
+	ageDesc := onose2tBuilder.NewMetricDesc(
+		"subscription_age_seconds",
+		"The seconds since the e2t subscription was first seen",
+		[]string{"id"}, staticLabelsE2t)
+	channelsDesc := onose2tBuilder.NewMetricDesc(
+		"subscription_channels",
+		"The number of channels of the e2t subscription",
+		[]string{"id"}, staticLabelsE2t)
+	notOpenDesc := onose2tBuilder.NewMetricDesc(
+		"subscription_not_open_seconds",
+		"The seconds since the e2t subscription left the OPEN phase, zero while it is OPEN",
+		[]string{"id"}, staticLabelsE2t)
 
 	for _, e2tSub := range c.Subs {
-		metric := onose2tBuilder.MustNewConstMetric(
+		l.add(revisionDesc, prometheus.GaugeValue, float64(e2tSub.Revision), e2tSub.Id)
+		l.add(ageDesc, prometheus.GaugeValue, e2tSub.Age, e2tSub.Id)
+		l.add(notOpenDesc, prometheus.GaugeValue, e2tSub.NotOpen, e2tSub.Id)
+		l.add(channelsDesc, prometheus.GaugeValue, float64(e2tSub.Channels), e2tSub.Id)
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
 			1,
 			e2tSub.Id,
-			e2tSub.Revision,
+			strconv.FormatUint(e2tSub.Revision, 10),
 			e2tSub.ServiceModelName,
 			e2tSub.ServiceModelVersion,
 			e2tSub.E2NodeID,
@@ -61,8 +126,58 @@ func (c *onosE2tSubscriptions) PrometheusFormat() ([]prometheus.Metric, error) {
 			e2tSub.StatusPhase,
 			e2tSub.StatusState,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for onosE2tChannels.
+func (c *onosE2tChannels) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	labels := []string{"id", "app_id", "app_instance_id", "node_id", "subscription_id", "service_model_name", "status_phase", "status_state"}
+	metricDesc := onose2tBuilder.NewMetricDesc(c.name, c.description, labels, staticLabelsE2t)
+
+	for _, channel := range c.Channels {
+		l.add(
+			metricDesc,
+			prometheus.GaugeValue,
+			1,
+			channel.Id,
+			channel.AppID,
+			channel.AppInstanceID,
+			channel.E2NodeID,
+			channel.SubscriptionID,
+			channel.ServiceModelName,
+			channel.StatusPhase,
+			channel.StatusState,
+		)
+	}
+
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for onosE2tSubscriptionLifecycle.
+func (c *onosE2tSubscriptionLifecycle) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	labels := []string{"service_model_name"}
+	for _, counter := range []struct {
+		name   string
+		help   string
+		values map[string]uint64
+	}{
+		{"subscriptions_created_total", "The number of e2t subscriptions created", c.Created},
+		{"subscriptions_deleted_total", "The number of e2t subscriptions deleted", c.Deleted},
+		{"subscriptions_failed_total", "The number of e2t subscriptions failed", c.Failed},
+	} {
+		desc := onose2tBuilder.NewMetricDesc(counter.name, counter.help, labels, staticLabelsE2t)
+		for serviceModel, value := range counter.values {
+			l.add(desc, prometheus.CounterValue, float64(value), serviceModel)
+		}
+	}
+
+	return l.samples()
 }
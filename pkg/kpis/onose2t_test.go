@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_E2tSubscriptionRevision(t *testing.T) {
+	kpi := OnosE2tSubscriptions()
+	kpi.Subs = map[string]E2tSubscription{
+		"sub-1": {Id: "sub-1", Revision: 7, ServiceModelName: "oran-e2sm-kpm"},
+	}
+
+	// The revision is exported both as the deprecated label of the
+	// subscription series and as a gauge.
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	revisions := map[string]string{}
+	for _, sample := range samples {
+		for _, label := range sample.Labels {
+			if label.Name == "revision" {
+				revisions[sample.Name] = label.Value
+			}
+		}
+		if sample.Name == "onos_e2t_subscription_revision" {
+			assert.Equal(t, 7.0, sample.Value)
+		}
+	}
+	assert.Equal(t, map[string]string{"onos_e2t_subscriptions": "7"}, revisions)
+}
@@ -5,15 +5,14 @@
 package kpis
 
 import (
-	"github.com/onosproject/onos-lib-go/pkg/prom"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of e2t metrics onose2tBuilder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsProf   = map[string]string{"sdran": "profile"}
-	onosProfileBuilder = prom.NewBuilder("onos", "profile", staticLabelsProf)
+	onosProfileBuilder = newBuilder("onos", "profile")
 )
 
 type HeapObject struct {
@@ -23,22 +22,26 @@ type HeapObject struct {
 	Format string
 }
 
+// onosProfileHeap is the kpi of the heap profiles of the onos
+// services. Objects stores the size of each heap object sampled, by
+// its name, source and format.
 type onosProfileHeap struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	Objects     map[string]HeapObject
 }
 
-func (c *onosProfileHeap) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for onosProfileHeap.
+func (c *onosProfileHeap) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
 	c.Labels = []string{"name", "source", "format"}
 	metricDesc := onosProfileBuilder.NewMetricDesc(c.name, c.description, c.Labels, staticLabelsProf)
 
 	for _, obj := range c.Objects {
-		metric := onosProfileBuilder.MustNewConstMetric(
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
 			float64(obj.Value),
@@ -46,8 +49,7 @@ func (c *onosProfileHeap) PrometheusFormat() ([]prometheus.Metric, error) {
 			obj.Source,
 			obj.Format,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
@@ -5,31 +5,52 @@
 package kpis
 
 import (
-	"github.com/onosproject/onos-lib-go/pkg/prom"
+	"sort"
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of onos topo metrics builder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsOnosTopo = map[string]string{"sdran": "topo"}
-	onosTopoBuilder      = prom.NewBuilder("onos", "topo", staticLabelsOnosTopo)
+	onosTopoBuilder      = newBuilder("onos", "topo")
 )
 
+// TopoRelation defines a topo relation. Labels and Aspects encode its
+// labels (as key=value) and aspect types, sorted and separated by
+// commas, and Promoted the values of its labels promoted to
+// Prometheus labels, by topo label key.
 type TopoRelation struct {
-	ID      string
-	Kind    string
-	Source  string
-	Target  string
-	Labels  string
-	Aspects string
+	ID       string
+	Kind     string
+	Source   string
+	Target   string
+	Labels   string
+	Aspects  string
+	Promoted map[string]string
 }
 
+// TopoEntity defines a topo entity, its labels and aspects are
+// encoded like the ones of TopoRelation.
 type TopoEntity struct {
-	ID      string
-	Kind    string
-	Labels  string
-	Aspects string
+	ID       string
+	Kind     string
+	Labels   string
+	Aspects  string
+	Promoted map[string]string
+}
+
+// TopoAspect defines an aspect of a topo object exported as an info
+// metric. ObjectType is the type of the object (e.g., entity), Type
+// the aspect type (e.g., onos.topo.E2Node), and Fields the values of
+// the scalar fields of the aspect by field name.
+type TopoAspect struct {
+	ObjectID   string
+	ObjectType string
+	Type       string
+	Fields     map[string]string
 }
 
 type TopoEntitySlice struct {
@@ -44,90 +65,230 @@ type TopoEntitySlice struct {
 	UeIdList      string
 }
 
+// topoRelations is the kpi of the onos topo relations, stored by
+// their id in Relations. PromotedLabels lists the keys of the topo
+// labels promoted to Prometheus labels, see TopoLabelName.
 type topoRelations struct {
-	name        string
-	description string
+	kpiBase
+	Labels         []string
+	LabelValues    []string
+	Relations      map[string]TopoRelation
+	PromotedLabels []string
+}
+
+// topoEntities is the kpi of the onos topo entities, stored by their
+// id in Entities. PromotedLabels lists the keys of the topo labels
+// promoted to Prometheus labels, see TopoLabelName.
+type topoEntities struct {
+	kpiBase
+	Labels         []string
+	LabelValues    []string
+	Entities       map[string]TopoEntity
+	PromotedLabels []string
+}
+
+// topoSlices is the kpi of the RAN slices of the onos topo E2 nodes,
+// stored by their id in Slices.
+type topoSlices struct {
+	kpiBase
 	Labels      []string
 	LabelValues []string
-	Relations   map[string]TopoRelation
+	Slices      map[string]TopoEntitySlice
 }
 
-type topoEntities struct {
-	name        string
-	description string
+// TopoE2Node defines the RAN data decoded from the aspects of an E2
+// node entity: the number of Cells it contains, its ServiceModels,
+// and its mastership Term and Master (if it has a mastership state).
+type TopoE2Node struct {
+	ID            string
+	Cells         int
+	ServiceModels []TopoServiceModel
+	HasMastership bool
+	Term          uint64
+	Master        string
+}
+
+// TopoServiceModel defines a service model supported by an E2 node.
+type TopoServiceModel struct {
+	Name string
+	OID  string
+}
+
+// TopoE2Cell defines the RAN data decoded from the aspects of an E2
+// cell entity, and the E2 node that contains it (if any).
+type TopoE2Cell struct {
+	ID           string
+	NodeID       string
+	CellGlobalID string
+	CellType     string
+	PCI          uint32
+	EARFCN       uint32
+}
+
+// TopoLocation defines the geolocation of a topo entity, in degrees
+// and meters (WGS-84).
+type TopoLocation struct {
+	EntityID  string
+	Kind      string
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+// topoRAN is the kpi of the RAN data decoded from the aspects of the
+// topo entities: the E2 Nodes and Cells by entity ID, and the
+// Locations of the entities with one.
+type topoRAN struct {
+	kpiBase
 	Labels      []string
 	LabelValues []string
-	Entities    map[string]TopoEntity
+	Nodes       map[string]TopoE2Node
+	Cells       map[string]TopoE2Cell
+	Locations   map[string]TopoLocation
 }
 
-type topoSlices struct {
-	name        string
-	description string
+// TopoE2Control defines an E2 control relation, from the E2T instance
+// E2TID to the E2 node NodeID. Master tells if it is the relation
+// the mastership of the node is granted to, and Age the seconds since
+// it was first seen.
+type TopoE2Control struct {
+	RelationID string
+	E2TID      string
+	NodeID     string
+	Master     bool
+	Age        float64
+}
+
+// TopoE2Mastership defines the mastership of an E2 node: its Term and
+// the control relation of its Master, if HasMaster, i.e., if the
+// relation exists. Changes counts the mastership changes seen so far.
+type TopoE2Mastership struct {
+	NodeID    string
+	Term      uint64
+	Master    string
+	HasMaster bool
+	Changes   uint64
+}
+
+// topoE2Controls is the kpi of the E2 connections of the E2 nodes.
+// It stores the E2 control relations by relation ID, the mastership
+// of the E2 nodes by node ID, and the IDs of the E2T instances, so
+// the ones without E2 nodes are exported too.
+type topoE2Controls struct {
+	kpiBase
 	Labels      []string
 	LabelValues []string
-	Slices      map[string]TopoEntitySlice
+	Controls    map[string]TopoE2Control
+	Nodes       map[string]TopoE2Mastership
+	E2TIDs      []string
+}
+
+// topoAspects is the kpi of the selected aspects of the topo objects.
+// Aspects stores the aspects exported as info metrics, one metric by
+// aspect type.
+type topoAspects struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Aspects     []TopoAspect
+}
+
+// TopoLabelName returns the name of the Prometheus label a topo label
+// is promoted to, i.e., its sanitized key prefixed by label_ (e.g.,
+// label_plmnid), so it does not clash with the other labels.
+func TopoLabelName(key string) string {
+	return "label_" + SanitizeMetricName(key)
+}
+
+// promotedLabels returns the names and the values of the topo labels
+// promoted, in the order of keys. A key promoted to the same label as
+// a previous key is skipped, so the labels are unique.
+func promotedLabels(keys []string, promoted map[string]string) ([]string, []string) {
+	names := make([]string, 0, len(keys))
+	values := make([]string, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		name := TopoLabelName(key)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		values = append(values, promoted[key])
+	}
+	return names, values
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for topoRelations.
-func (t *topoRelations) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (t *topoRelations) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
-	t.Labels = []string{"relationid", "kind", "source", "target", "labels", "aspects"}
+	promotedNames, _ := promotedLabels(t.PromotedLabels, nil)
+	t.Labels = append([]string{"relationid", "kind", "source", "target", "labels", "aspects"}, promotedNames...)
 	metricDesc := onosTopoBuilder.NewMetricDesc(t.name, t.description, t.Labels, staticLabelsOnosTopo)
 
 	for _, relation := range t.Relations {
-		metric := onosTopoBuilder.MustNewConstMetric(
-			metricDesc,
-			prometheus.GaugeValue,
-			1.0,
+		_, promotedValues := promotedLabels(t.PromotedLabels, relation.Promoted)
+		labelValues := append([]string{
 			relation.ID,
 			relation.Kind,
 			relation.Source,
 			relation.Target,
 			relation.Labels,
 			relation.Aspects,
+		}, promotedValues...)
+
+		l.add(
+			metricDesc,
+			prometheus.GaugeValue,
+			1.0,
+			labelValues...,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for topoEntities.
-func (t *topoEntities) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (t *topoEntities) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
-	t.Labels = []string{"entityid", "kind", "labels", "aspects"}
+	promotedNames, _ := promotedLabels(t.PromotedLabels, nil)
+	t.Labels = append([]string{"entityid", "kind", "labels", "aspects"}, promotedNames...)
 	metricDesc := onosTopoBuilder.NewMetricDesc(t.name, t.description, t.Labels, staticLabelsOnosTopo)
 
 	for _, entity := range t.Entities {
-		metric := onosTopoBuilder.MustNewConstMetric(
-			metricDesc,
-			prometheus.GaugeValue,
-			1.0,
+		_, promotedValues := promotedLabels(t.PromotedLabels, entity.Promoted)
+		labelValues := append([]string{
 			entity.ID,
 			entity.Kind,
 			entity.Labels,
 			entity.Aspects,
+		}, promotedValues...)
+
+		l.add(
+			metricDesc,
+			prometheus.GaugeValue,
+			1.0,
+			labelValues...,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for topoSlices.
-func (t *topoSlices) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (t *topoSlices) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
 	t.Labels = []string{"entityid", "kind", "slice_id", "slice_desc", "scheduler_type", "weight", "qoslevel", "slice_type", "ue_id_list"}
 	metricDesc := onosTopoBuilder.NewMetricDesc(t.name, t.description, t.Labels, staticLabelsOnosTopo)
 
 	for _, entitySlice := range t.Slices {
-		metric := onosTopoBuilder.MustNewConstMetric(
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
 			1.0,
@@ -141,8 +302,229 @@ func (t *topoSlices) PrometheusFormat() ([]prometheus.Metric, error) {
 			entitySlice.SliceType,
 			entitySlice.UeIdList,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for topoAspects.
+// The aspects of each type are exported as the info metric
+// aspect_<sanitized type>_info, with the object_id and object_type
+// labels, and a label by sanitized field name. The fields missing
+// from an aspect have empty labels, so all the aspects of a type
+// have the same label names.
+// A field whose sanitized name is taken, by a fixed label or by a
+// field before it in sorted order, is prefixed by field_, and skipped
+// if that name is taken too. An aspect type whose metric name is taken
+// by a type before it in sorted order is skipped.
+func (t *topoAspects) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	aspectFields := map[string]map[string]bool{}
+	for _, aspect := range t.Aspects {
+		if aspectFields[aspect.Type] == nil {
+			aspectFields[aspect.Type] = map[string]bool{}
+		}
+		for field := range aspect.Fields {
+			aspectFields[aspect.Type][field] = true
+		}
+	}
+	aspectTypes := make([]string, 0, len(aspectFields))
+	for aspectType := range aspectFields {
+		aspectTypes = append(aspectTypes, aspectType)
+	}
+	sort.Strings(aspectTypes)
+
+	aspectDescs := map[string]*metricDesc{}
+	aspectLabels := map[string][]string{}
+	labelFields := map[string]map[string]string{}
+	metricNames := map[string]bool{}
+	for _, aspectType := range aspectTypes {
+		metricName := "aspect_" + SanitizeMetricName(aspectType) + "_info"
+		if metricNames[metricName] {
+			continue
+		}
+		metricNames[metricName] = true
+
+		fields := make([]string, 0, len(aspectFields[aspectType]))
+		for field := range aspectFields[aspectType] {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		taken := map[string]bool{"": true, "object_id": true, "object_type": true}
+		for name := range staticLabelsOnosTopo {
+			taken[name] = true
+		}
+		fieldLabels := make([]string, 0, len(fields))
+		labelFields[aspectType] = make(map[string]string, len(fields))
+		for _, field := range fields {
+			label := SanitizeMetricName(field)
+			if taken[label] {
+				label = "field_" + label
+			}
+			if taken[label] {
+				continue
+			}
+			taken[label] = true
+			fieldLabels = append(fieldLabels, label)
+			labelFields[aspectType][label] = field
+		}
+		sort.Strings(fieldLabels)
+
+		labels := append([]string{"object_id", "object_type"}, fieldLabels...)
+		aspectLabels[aspectType] = fieldLabels
+		aspectDescs[aspectType] = onosTopoBuilder.NewMetricDesc(
+			metricName,
+			"The "+aspectType+" aspect of the topo objects",
+			labels, staticLabelsOnosTopo)
+	}
+
+	for _, aspect := range t.Aspects {
+		if aspectDescs[aspect.Type] == nil {
+			continue
+		}
+
+		labelValues := []string{aspect.ObjectID, aspect.ObjectType}
+		for _, label := range aspectLabels[aspect.Type] {
+			labelValues = append(labelValues, aspect.Fields[labelFields[aspect.Type][label]])
+		}
+
+		l.add(
+			aspectDescs[aspect.Type],
+			prometheus.GaugeValue,
+			1.0,
+			labelValues...,
+		)
+	}
+
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for topoRAN.
+func (t *topoRAN) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	cellsDesc := onosTopoBuilder.NewMetricDesc(
+		"e2node_cells",
+		"The number of cells of the E2 node",
+		[]string{"node_id"}, staticLabelsOnosTopo)
+	serviceModelDesc := onosTopoBuilder.NewMetricDesc(
+		"e2node_service_model_info",
+		"The service models supported by the E2 node",
+		[]string{"node_id", "service_model_name", "service_model_oid"}, staticLabelsOnosTopo)
+	termDesc := onosTopoBuilder.NewMetricDesc(
+		"e2node_mastership_term",
+		"The mastership term of the E2 node",
+		[]string{"node_id", "master_id"}, staticLabelsOnosTopo)
+
+	for _, node := range t.Nodes {
+		l.add(cellsDesc, prometheus.GaugeValue, float64(node.Cells), node.ID)
+		for _, sm := range node.ServiceModels {
+			l.add(serviceModelDesc, prometheus.GaugeValue, 1.0, node.ID, sm.Name, sm.OID)
+		}
+		if node.HasMastership {
+			l.add(termDesc, prometheus.GaugeValue, float64(node.Term), node.ID, node.Master)
+		}
+	}
+
+	cellLabels := []string{"cell_id", "node_id", "cell_global_id", "cell_type"}
+	pciDesc := onosTopoBuilder.NewMetricDesc(
+		"e2cell_pci",
+		"The physical cell ID of the E2 cell",
+		cellLabels, staticLabelsOnosTopo)
+	earfcnDesc := onosTopoBuilder.NewMetricDesc(
+		"e2cell_earfcn",
+		"The EARFCN of the E2 cell",
+		cellLabels, staticLabelsOnosTopo)
+
+	for _, cell := range t.Cells {
+		l.add(pciDesc, prometheus.GaugeValue, float64(cell.PCI), cell.ID, cell.NodeID, cell.CellGlobalID, cell.CellType)
+		l.add(earfcnDesc, prometheus.GaugeValue, float64(cell.EARFCN), cell.ID, cell.NodeID, cell.CellGlobalID, cell.CellType)
+	}
+
+	locationDesc := onosTopoBuilder.NewMetricDesc(
+		"location_info",
+		"The geolocation of the topo entity",
+		[]string{"entityid", "kind", "latitude", "longitude", "altitude"}, staticLabelsOnosTopo)
+
+	for _, location := range t.Locations {
+		l.add(
+			locationDesc,
+			prometheus.GaugeValue,
+			1.0,
+			location.EntityID,
+			location.Kind,
+			strconv.FormatFloat(location.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(location.Longitude, 'f', -1, 64),
+			strconv.FormatFloat(location.Altitude, 'f', -1, 64),
+		)
+	}
+
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for topoE2Controls.
+func (t *topoE2Controls) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	connectedDesc := onosTopoBuilder.NewMetricDesc(
+		"e2t_connected_nodes",
+		"The number of E2 nodes controlled by the E2T instance",
+		[]string{"e2t_id"}, staticLabelsOnosTopo)
+	controlLabels := []string{"relation_id", "e2t_id", "node_id"}
+	ageDesc := onosTopoBuilder.NewMetricDesc(
+		"e2_connection_age_seconds",
+		"The seconds since the E2 control relation was first seen",
+		controlLabels, staticLabelsOnosTopo)
+	masterDesc := onosTopoBuilder.NewMetricDesc(
+		"e2_connection_master",
+		"Whether the mastership of the E2 node is granted to the E2 control relation",
+		controlLabels, staticLabelsOnosTopo)
+
+	// An E2 node is counted once by E2T instance, whatever the
+	// number of its control relations.
+	connected := map[string]map[string]bool{}
+	for _, e2tID := range t.E2TIDs {
+		connected[e2tID] = map[string]bool{}
+	}
+	for _, control := range t.Controls {
+		if connected[control.E2TID] == nil {
+			connected[control.E2TID] = map[string]bool{}
+		}
+		connected[control.E2TID][control.NodeID] = true
+
+		master := 0.0
+		if control.Master {
+			master = 1.0
+		}
+		l.add(ageDesc, prometheus.GaugeValue, control.Age, control.RelationID, control.E2TID, control.NodeID)
+		l.add(masterDesc, prometheus.GaugeValue, master, control.RelationID, control.E2TID, control.NodeID)
+	}
+	for e2tID, nodes := range connected {
+		l.add(connectedDesc, prometheus.GaugeValue, float64(len(nodes)), e2tID)
+	}
+
+	changesDesc := onosTopoBuilder.NewMetricDesc(
+		"e2node_mastership_changes_total",
+		"The number of mastership changes of the E2 node seen by the exporter",
+		[]string{"node_id"}, staticLabelsOnosTopo)
+	hasMasterDesc := onosTopoBuilder.NewMetricDesc(
+		"e2node_has_master",
+		"Whether the mastership of the E2 node is granted to an existing E2 control relation",
+		[]string{"node_id"}, staticLabelsOnosTopo)
+
+	for _, node := range t.Nodes {
+		hasMaster := 0.0
+		if node.HasMaster {
+			hasMaster = 1.0
+		}
+		l.add(changesDesc, prometheus.CounterValue, float64(node.Changes), node.NodeID)
+		l.add(hasMasterDesc, prometheus.GaugeValue, hasMaster, node.NodeID)
+	}
+
+	return l.samples()
 }
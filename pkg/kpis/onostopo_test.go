@@ -0,0 +1,84 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TopoPromotedLabels(t *testing.T) {
+	assert.Equal(t, "label_plmnid", TopoLabelName("plmnid"))
+	assert.Equal(t, "label_onos_kind", TopoLabelName("onos.kind"))
+
+	kpi := OnosTopoEntities()
+	kpi.PromotedLabels = []string{"plmnid", "tac"}
+	kpi.Entities = map[string]TopoEntity{
+		"e2:1": {ID: "e2:1", Promoted: map[string]string{"plmnid": "138426", "tac": "1"}},
+		"e2:2": {ID: "e2:2"},
+	}
+
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Contains(t, samples[0].Labels, Label{Name: "label_plmnid", Value: "138426"})
+}
+
+func Test_TopoAspectsFormat(t *testing.T) {
+	kpi := OnosTopoAspects()
+	kpi.Aspects = []TopoAspect{
+		{ObjectID: "e2:1", ObjectType: "entity", Type: "onos.topo.Location", Fields: map[string]string{"lat": "52.5"}},
+		{ObjectID: "e2:2", ObjectType: "entity", Type: "onos.topo.Location", Fields: map[string]string{"lng": "13.4"}},
+		{ObjectID: "e2:1", ObjectType: "entity", Type: "onos.topo.E2Node"},
+	}
+
+	// The aspects of a type have the same label names, even if their
+	// fields differ.
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 3)
+}
+
+func Test_TopoLabelCollisions(t *testing.T) {
+	// A topo label promoted to the label of a previous one is skipped.
+	kpi := OnosTopoEntities()
+	kpi.PromotedLabels = []string{"onos.kind", "onos-kind"}
+	kpi.Entities = map[string]TopoEntity{
+		"e2:1": {ID: "e2:1", Promoted: map[string]string{"onos.kind": "a", "onos-kind": "b"}},
+	}
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 1)
+	assert.Contains(t, samples[0].Labels, Label{Name: "label_onos_kind", Value: "a"})
+	assert.NotContains(t, samples[0].Labels, Label{Name: "label_onos_kind", Value: "b"})
+
+	// The fields that clash with a fixed label or another field are
+	// prefixed by field_, and skipped if that clashes too, and the
+	// types exported as the same metric as another are skipped.
+	aspects := OnosTopoAspects()
+	aspects.Aspects = []TopoAspect{
+		{ObjectID: "e2:1", ObjectType: "entity", Type: "onos.topo.Location", Fields: map[string]string{
+			"object.id": "0", "object_id": "1", "sdran": "2", "a.b": "3", "a_b": "4",
+		}},
+		{ObjectID: "e2:1", ObjectType: "entity", Type: "onos_topo_location", Fields: map[string]string{"lat": "52.5"}},
+	}
+	samples, err = aspects.Samples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 1)
+	labels := map[string]string{}
+	for _, label := range samples[0].Labels {
+		labels[label.Name] = label.Value
+	}
+	assert.Equal(t, map[string]string{
+		"object_id":       "e2:1",
+		"object_type":     "entity",
+		"sdran":           "topo",
+		"a_b":             "3",
+		"field_a_b":       "4",
+		"field_object_id": "0",
+		"field_sdran":     "2",
+	}, labels)
+}
@@ -5,15 +5,14 @@
 package kpis
 
 import (
-	"github.com/onosproject/onos-lib-go/pkg/prom"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of onos uenib metrics builder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsOnosUenib = map[string]string{"sdran": "uenib"}
-	onosUenibBuilder      = prom.NewBuilder("onos", "uenib", staticLabelsOnosUenib)
+	onosUenibBuilder      = newBuilder("onos", "uenib")
 )
 
 type UE struct {
@@ -22,18 +21,50 @@ type UE struct {
 	Relations map[string]TopoRelation
 }
 
+// onosUenibUEs is the kpi of the UEs of the uenib, stored by their
+// id in UEs with their aspect types.
 type onosUenibUEs struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	UEs         map[string]UE
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// onosUenibUEChanges is the kpi of the UE churn of the uenib in watch
+// mode. Added and Removed count the UEs added to and removed from the
+// uenib since the collector started watching it.
+type onosUenibUEChanges struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Added       uint64
+	Removed     uint64
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for onosUenibUEChanges.
+func (t *onosUenibUEChanges) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	addedDesc := onosUenibBuilder.NewMetricDesc(
+		t.name+"_added_total",
+		"The number of UEs added to the uenib since the collector started watching it",
+		t.Labels, staticLabelsOnosUenib)
+	removedDesc := onosUenibBuilder.NewMetricDesc(
+		t.name+"_removed_total",
+		"The number of UEs removed from the uenib since the collector started watching it",
+		t.Labels, staticLabelsOnosUenib)
+
+	l.add(addedDesc, prometheus.CounterValue, float64(t.Added))
+	l.add(removedDesc, prometheus.CounterValue, float64(t.Removed))
+
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for onosUenibUEs.
-func (t *onosUenibUEs) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (t *onosUenibUEs) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
 	for _, ue := range t.UEs {
 		t.Labels = []string{"ueid", "aspects"}
@@ -41,14 +72,13 @@ func (t *onosUenibUEs) PrometheusFormat() ([]prometheus.Metric, error) {
 
 		metricDesc := onosUenibBuilder.NewMetricDesc(t.name, t.description, t.Labels, staticLabelsOnosUenib)
 
-		metric := onosUenibBuilder.MustNewConstMetric(
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
 			1.0,
 			t.LabelValues...,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
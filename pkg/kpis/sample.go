@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SampleType defines the type of the metric of a Sample.
+type SampleType string
+
+// Const definitions of the types of the metrics of the samples.
+const (
+	SampleGauge     SampleType = "gauge"
+	SampleCounter   SampleType = "counter"
+	SampleInfo      SampleType = "info"
+	SampleSummary   SampleType = "summary"
+	SampleHistogram SampleType = "histogram"
+	SampleUnknown   SampleType = "unknown"
+)
+
+// baseUnits are the units recognized as the suffix of a metric name,
+// before the _total suffix of a counter.
+var baseUnits = []string{"seconds", "bytes"}
+
+// Label defines a label of a Sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample defines a sample of a metric, independent of the format it is
+// exported in. Name is the name of the metric, with the _total suffix
+// of a counter and the _info suffix of an info metric, Help its HELP
+// text, Type its type, and Unit its unit, which is the suffix of Name
+// (before _total), if any. Suffix is the suffix of the series of a
+// summary or histogram (e.g., _sum), empty for the other types.
+// Labels are the labels of the series, including the static labels
+// of the metric, sorted by name, and Timestamp the time of the sample,
+// zero if unknown.
+type Sample struct {
+	Name      string
+	Suffix    string
+	Help      string
+	Type      SampleType
+	Unit      string
+	Labels    []Label
+	Value     float64
+	Timestamp time.Time
+}
+
+// builder builds the metricDesc of the metrics of a subsystem, like
+// the prom.Builder of onos-lib-go builds a prometheus.Desc.
+type builder struct {
+	namespace string
+	subsystem string
+}
+
+func newBuilder(namespace, subsystem string) *builder {
+	return &builder{
+		namespace: namespace,
+		subsystem: subsystem,
+	}
+}
+
+// metricDesc defines the name, HELP text, unit and labels of a metric.
+type metricDesc struct {
+	name         string
+	help         string
+	unit         string
+	labels       []string
+	staticLabels []Label
+}
+
+// NewMetricDesc returns the metricDesc of the metric name of the
+// subsystem of b, with the labels and staticLabels given. Its unit is
+// the suffix of the name that is a base unit (e.g., seconds), if any.
+func (b *builder) NewMetricDesc(name, help string, labels []string, staticLabels map[string]string) *metricDesc {
+	desc := &metricDesc{
+		name:   prometheus.BuildFQName(b.namespace, b.subsystem, name),
+		help:   help,
+		labels: labels,
+	}
+
+	for _, unit := range baseUnits {
+		if strings.HasSuffix(strings.TrimSuffix(desc.name, "_total"), "_"+unit) {
+			desc.unit = unit
+		}
+	}
+
+	// The static labels are sorted, so the key of equal descs is the same.
+	for name, value := range staticLabels {
+		desc.staticLabels = append(desc.staticLabels, Label{Name: name, Value: value})
+	}
+	sort.Slice(desc.staticLabels, func(i, j int) bool {
+		return desc.staticLabels[i].Name < desc.staticLabels[j].Name
+	})
+
+	return desc
+}
+
+// String returns the key of d, so the series of equal descs built apart
+// are kept as the same metric.
+func (d *metricDesc) String() string {
+	return fmt.Sprintf("%s %v %v", d.name, d.labels, d.staticLabels)
+}
+
+// sampleType returns the SampleType of a series of d of valueType.
+// A gauge whose name ends with _info is an info metric.
+func (d *metricDesc) sampleType(valueType prometheus.ValueType) SampleType {
+	switch valueType {
+	case prometheus.CounterValue:
+		return SampleCounter
+	case prometheus.GaugeValue:
+		if strings.HasSuffix(d.name, "_info") {
+			return SampleInfo
+		}
+		return SampleGauge
+	default:
+		return SampleUnknown
+	}
+}
+
+// validate checks that d is a valid metric for a series of valueType
+// with labelValues. The name of a counter must end with _total, so
+// its samples are named the same in every format.
+func (d *metricDesc) validate(valueType prometheus.ValueType, labelValues []string) error {
+	if !ValidMetricName(d.name) {
+		return fmt.Errorf("%q is not a valid metric name", d.name)
+	}
+	if d.sampleType(valueType) == SampleCounter && !strings.HasSuffix(d.name, "_total") {
+		return fmt.Errorf("counter %s does not end with _total", d.name)
+	}
+	for _, label := range d.labels {
+		if !ValidMetricName(label) || strings.HasPrefix(label, "__") {
+			return fmt.Errorf("%q is not a valid label name of %s", label, d.name)
+		}
+	}
+	if len(labelValues) != len(d.labels) {
+		return fmt.Errorf("%d label values for the %d labels of %s", len(labelValues), len(d.labels), d.name)
+	}
+	return nil
+}
+
+// sample returns the Sample of a series of d.
+func (d *metricDesc) sample(valueType prometheus.ValueType, value float64, timestamp time.Time, labelValues []string) Sample {
+	labels := make([]Label, 0, len(d.labels)+len(d.staticLabels))
+	for i, name := range d.labels {
+		labels = append(labels, Label{Name: name, Value: labelValues[i]})
+	}
+	labels = append(labels, d.staticLabels...)
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+
+	return Sample{
+		Name:      d.name,
+		Help:      d.help,
+		Type:      d.sampleType(valueType),
+		Unit:      d.unit,
+		Labels:    labels,
+		Value:     value,
+		Timestamp: timestamp,
+	}
+}
+
+// PrometheusMetrics returns the prometheus.Metric of each of the
+// samples. A sample that can not be turned into a metric is left out,
+// and reported in the error returned along with the other metrics.
+func PrometheusMetrics(samples []Sample) ([]prometheus.Metric, error) {
+	metrics := make([]prometheus.Metric, 0, len(samples))
+	descs := make(map[string]*prometheus.Desc)
+	failed := 0
+	var lastErr error
+
+	for _, sample := range samples {
+		labelNames := make([]string, 0, len(sample.Labels))
+		labelValues := make([]string, 0, len(sample.Labels))
+		for _, label := range sample.Labels {
+			labelNames = append(labelNames, label.Name)
+			labelValues = append(labelValues, label.Value)
+		}
+
+		name := sample.Name + sample.Suffix
+		key := name + "\xff" + strings.Join(labelNames, "\xff")
+		desc, ok := descs[key]
+		if !ok {
+			desc = prometheus.NewDesc(name, sample.Help, labelNames, nil)
+			descs[key] = desc
+		}
+
+		valueType := prometheus.UntypedValue
+		switch sample.Type {
+		case SampleCounter:
+			valueType = prometheus.CounterValue
+		case SampleGauge, SampleInfo:
+			valueType = prometheus.GaugeValue
+		}
+
+		metric, err := prometheus.NewConstMetric(desc, valueType, sample.Value, labelValues...)
+		if err != nil {
+			failed++
+			lastErr = err
+			continue
+		}
+		if !sample.Timestamp.IsZero() {
+			metric = prometheus.NewMetricWithTimestamp(sample.Timestamp, metric)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	if failed > 0 {
+		return metrics, fmt.Errorf("%d samples not exported, last error: %s", failed, lastErr)
+	}
+	return metrics, nil
+}
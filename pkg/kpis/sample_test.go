@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Samples(t *testing.T) {
+	b := newBuilder("onos", "test")
+	staticLabels := map[string]string{"sys": "test", "app": "exporter"}
+	ageDesc := b.NewMetricDesc("age_seconds", "The age", []string{"id"}, staticLabels)
+	cpuDesc := b.NewMetricDesc("cpu_seconds_total", "The cpu time", nil, nil)
+	infoDesc := b.NewMetricDesc("build_info", "The build", []string{"version"}, nil)
+	assert.Equal(t, "seconds", ageDesc.unit)
+	assert.Equal(t, "seconds", cpuDesc.unit)
+	assert.Equal(t, "", infoDesc.unit)
+
+	timestamp := time.Unix(1630000000, 0)
+	l := newLimiter(Limits{})
+	l.addWithTimestamp(ageDesc, timestamp, prometheus.GaugeValue, 1.5, "1")
+	l.add(cpuDesc, prometheus.CounterValue, 3)
+	l.add(infoDesc, prometheus.GaugeValue, 1, "v1")
+	l.add(infoDesc, prometheus.GaugeValue, 1, "v1", "extra")
+
+	samples, dropped, err := l.samples()
+	assert.Error(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Equal(t, []Sample{
+		{
+			Name:      "onos_test_age_seconds",
+			Help:      "The age",
+			Type:      SampleGauge,
+			Unit:      "seconds",
+			Labels:    []Label{{"app", "exporter"}, {"id", "1"}, {"sys", "test"}},
+			Value:     1.5,
+			Timestamp: timestamp,
+		},
+		{
+			Name:   "onos_test_cpu_seconds_total",
+			Help:   "The cpu time",
+			Type:   SampleCounter,
+			Unit:   "seconds",
+			Labels: []Label{},
+			Value:  3,
+		},
+		{
+			Name:   "onos_test_build_info",
+			Help:   "The build",
+			Type:   SampleInfo,
+			Labels: []Label{{"version", "v1"}},
+			Value:  1,
+		},
+	}, samples)
+
+	metrics, err := PrometheusMetrics(samples)
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 3)
+
+	_, err = PrometheusMetrics([]Sample{{Name: "not a name", Type: SampleGauge}})
+	assert.Error(t, err)
+
+	// A counter that does not end with _total is left out.
+	l = newLimiter(Limits{})
+	l.add(b.NewMetricDesc("requests", "The requests", nil, nil), prometheus.CounterValue, 1)
+	l.add(b.NewMetricDesc("requests_total", "The requests", nil, nil), prometheus.CounterValue, 1)
+	samples, _, err = l.samples()
+	assert.EqualError(t, err, "1 series not exported, last error: counter onos_test_requests does not end with _total")
+	assert.Len(t, samples, 1)
+	assert.Equal(t, "onos_test_requests_total", samples[0].Name)
+}
+
+func Test_PrometheusFormat(t *testing.T) {
+	kpi := OnosTopoSlices()
+	kpi.Slices = map[string]TopoEntitySlice{
+		"1": {NodeID: "e2:1", SliceID: "1", UeIdList: "1,2"},
+		"2": {NodeID: "e2:1", SliceID: "2", UeIdList: "3"},
+	}
+
+	samples, err := kpi.Samples()
+	assert.NoError(t, err)
+	metrics, err := kpi.PrometheusFormat()
+	assert.NoError(t, err)
+	assert.Len(t, metrics, len(samples))
+	assert.Contains(t, metrics[0].Desc().String(), samples[0].Name)
+}
@@ -5,68 +5,191 @@
 package kpis
 
 import (
-	"strconv"
+	"regexp"
 	"strings"
+	"time"
 
-	"github.com/onosproject/onos-lib-go/pkg/prom"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of xapp kpimon metrics builder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsXappKpimon = map[string]string{"sdran": "xappkpimon"}
-	xappKpimonBuilder      = prom.NewBuilder("onos", "xappkpimon", staticLabelsXappKpimon)
+	xappKpimonBuilder      = newBuilder("onos", "xappkpimon")
 )
 
+// KpimonValueKind defines the kind of value of a KPM measurement.
+type KpimonValueKind string
+
+// Const definitions of the kinds of value of a KPM measurement.
+const (
+	KpimonIntegerValue KpimonValueKind = "integer"
+	KpimonRealValue    KpimonValueKind = "real"
+	KpimonNoValue      KpimonValueKind = "none"
+)
+
+// KpimonData defines a KPM measurement of a cell, or of a UE and/or
+// a slice of the cell if UEID and/or SliceID are set.
+// Value is the value of the measurement, NaN if its Kind is
+// KpimonNoValue, and Timestamp the time of the measurement record,
+// zero if unknown.
 type KpimonData struct {
 	E2ID         string
 	NodeID       string
 	CellID       string
 	CellGlobalID string
+	UEID         string
+	SliceID      string
 	MetricType   string
-	Value        string
+	Value        float64
+	Kind         KpimonValueKind
+	Timestamp    time.Time
+}
+
+// KpimonMetric defines how a KPM measurement is exported.
+// Name is the name of its metric, sanitized from the measurement name
+// if empty, Help its HELP text, and Unit its unit, which is appended
+// to the metric name as a suffix (if not there yet).
+type KpimonMetric struct {
+	Measurement string
+	Name        string
+	Help        string
+	Unit        string
+}
+
+// defaultKpimonMetrics defines the HELP text of well known KPM
+// measurements (3GPP TS 28.552), keeping their sanitized names.
+var defaultKpimonMetrics = []KpimonMetric{
+	{Measurement: "RRC.Conn.Avg", Help: "The mean number of UEs in RRC connected mode"},
+	{Measurement: "RRC.Conn.Max", Help: "The max number of UEs in RRC connected mode"},
+	{Measurement: "RRC.ConnEstabAtt.Sum", Help: "The number of RRC connection establishment attempts"},
+	{Measurement: "RRC.ConnEstabSucc.Sum", Help: "The number of successful RRC connection establishments"},
+	{Measurement: "RRC.ConnReEstabAtt.Sum", Help: "The number of RRC connection re-establishment attempts"},
+	{Measurement: "DRB.UEThpDl", Help: "The DL UE throughput (kbit/s)"},
+	{Measurement: "DRB.UEThpUl", Help: "The UL UE throughput (kbit/s)"},
+	{Measurement: "RRU.PrbUsedDl", Help: "The number of DL PRBs used"},
+	{Measurement: "RRU.PrbUsedUl", Help: "The number of UL PRBs used"},
+	{Measurement: "RRU.PrbTotDl", Help: "The DL total PRB usage (%)"},
+	{Measurement: "RRU.PrbTotUl", Help: "The UL total PRB usage (%)"},
+}
+
+// KpimonMetrics returns the table of KpimonMetric by measurement name,
+// the default one with the metrics given overriding it.
+func KpimonMetrics(metrics []KpimonMetric) map[string]KpimonMetric {
+	table := make(map[string]KpimonMetric, len(defaultKpimonMetrics)+len(metrics))
+	for _, m := range defaultKpimonMetrics {
+		table[m.Measurement] = m
+	}
+	for _, m := range metrics {
+		table[m.Measurement] = m
+	}
+	return table
+}
+
+// MetricName returns the name of the metric of the KPM measurement,
+// with its unit as a suffix.
+func (m KpimonMetric) MetricName() string {
+	name := m.Name
+	if name == "" {
+		name = SanitizeMetricName(m.Measurement)
+	}
+	if m.Unit != "" {
+		unit := SanitizeMetricName(m.Unit)
+		if !strings.HasSuffix(name, "_"+unit) {
+			name += "_" + unit
+		}
+	}
+	return name
+}
+
+// SanitizeMetricName turns name into a valid Prometheus metric name,
+// e.g., RRC.Conn.Avg into rrc_conn_avg: it is lowercased, the chars
+// other than letters, digits and underscores are replaced by
+// underscores, and an underscore is prepended if it starts with
+// a digit.
+func SanitizeMetricName(name string) string {
+	var b strings.Builder
+	for i, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// ValidMetricName checks if name is a valid Prometheus metric name
+// (without colons, which are reserved to recording rules).
+func ValidMetricName(name string) bool {
+	return metricNameRE.MatchString(name)
 }
 
-// xappkpimon defines the common data that can be used
-// to output the format of a KPI (e.g., PrometheusFormat).
-// Data stores the KpimonData structure defined for each kpimon
-// metric.
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// xappkpimon is the kpi of the KPM measurements of kpimon.
+// Data stores the KpimonData structure defined for each record of
+// a kpimon metric, exported as a sample at its timestamp, and Metrics
+// the KpimonMetric of each measurement name, see KpimonMetrics (the
+// measurements missing from it are exported with their sanitized
+// name).
 type xappkpimon struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	Data        map[string]KpimonData
+	Metrics     map[string]KpimonMetric
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for xappkpimon.
-func (c *xappkpimon) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+// The metric of a measurement with Timestamp carries its timestamp,
+// so the TSDB stores it at the time it was measured.
+// A measurement that can not be exported (e.g., its name is not
+// a valid metric name) is left out, and reported in the error
+// returned along with the other metrics.
+func (c *xappkpimon) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
-	for _, data := range c.Data {
-		metricName := strings.ReplaceAll(strings.ToLower(data.MetricType), ".", "_")
-		metricValue, err := strconv.ParseInt(data.Value, 0, 64)
+	// The cell measurements have empty ue_id and slice_id labels, which
+	// Prometheus drops, so all the measurements of a metric have the
+	// same label names.
+	c.Labels = []string{"e2_id", "node_id", "cell_id", "cell_global_id", "ue_id", "slice_id"}
 
-		if err != nil {
-			return metrics, err
+	for _, data := range c.Data {
+		kpimonMetric, ok := c.Metrics[data.MetricType]
+		if !ok {
+			kpimonMetric = KpimonMetric{Measurement: data.MetricType}
+		}
+		help := kpimonMetric.Help
+		if help == "" {
+			help = c.description
+		}
+		metricDesc := xappKpimonBuilder.NewMetricDesc(kpimonMetric.MetricName(), help, c.Labels, staticLabelsXappKpimon)
+		if kpimonMetric.Unit != "" {
+			metricDesc.unit = SanitizeMetricName(kpimonMetric.Unit)
 		}
 
-		c.Labels = []string{"e2_id", "node_id", "cell_id", "cell_global_id"}
-		metricDesc := xappKpimonBuilder.NewMetricDesc(metricName, c.description, c.Labels, staticLabelsXappKpimon)
-
-		metric := xappKpimonBuilder.MustNewConstMetric(
+		l.addWithTimestamp(
 			metricDesc,
+			data.Timestamp,
 			prometheus.GaugeValue,
-			float64(metricValue),
+			data.Value,
 			data.E2ID,
 			data.NodeID,
 			data.CellID,
 			data.CellGlobalID,
+			data.UEID,
+			data.SliceID,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
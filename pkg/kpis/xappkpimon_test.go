@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2021-present Open Networking Foundation <info@opennetworking.org>
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kpis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KpimonMetricName(t *testing.T) {
+	assert.Equal(t, "rrc_conn_avg", SanitizeMetricName("RRC.Conn.Avg"))
+	assert.Equal(t, "drb_uethpdl", SanitizeMetricName("DRB.UEThpDl"))
+	assert.Equal(t, "_5qi_flow_count", SanitizeMetricName("5QI-Flow:Count"))
+
+	metrics := KpimonMetrics([]KpimonMetric{
+		{Measurement: "DRB.UEThpDl", Name: "drb_ue_throughput_dl", Help: "DL throughput", Unit: "kbps"},
+	})
+	assert.Equal(t, "drb_ue_throughput_dl_kbps", metrics["DRB.UEThpDl"].MetricName())
+	assert.Equal(t, "rrc_conn_avg", metrics["RRC.Conn.Avg"].MetricName())
+	assert.NotEmpty(t, metrics["RRC.Conn.Avg"].Help)
+
+	assert.True(t, ValidMetricName("rrc_conn_avg"))
+	assert.False(t, ValidMetricName("rrc.conn.avg"))
+}
+
+func Test_KpimonSamples(t *testing.T) {
+	kpi := XappKpiMon()
+	kpi.Data = map[string]KpimonData{
+		"e2:1:1:1:RRC.Conn.Avg": {MetricType: "RRC.Conn.Avg", Value: 0.5, Kind: KpimonRealValue},
+		"e2:1:1:1:DRB.UEThpDl":  {MetricType: "DRB.UEThpDl", Value: 10, Kind: KpimonIntegerValue},
+	}
+	kpi.Metrics = KpimonMetrics([]KpimonMetric{{Measurement: "DRB.UEThpDl", Name: "drb-thp"}})
+
+	samples, err := kpi.Samples()
+	assert.Error(t, err)
+	assert.Len(t, samples, 1)
+}
+
+func Test_KpimonRecordSamples(t *testing.T) {
+	t1 := time.Unix(1630000000, 0)
+	t2 := t1.Add(time.Second)
+
+	kpi := XappKpiMon()
+	kpi.Data = map[string]KpimonData{
+		"e2:1:1:1:RRC.Conn.Avg@1630000001": {E2ID: "e2", MetricType: "RRC.Conn.Avg", Value: 2, Timestamp: t2},
+		"e2:1:1:1:RRC.Conn.Avg@1630000000": {E2ID: "e2", MetricType: "RRC.Conn.Avg", Value: 1, Timestamp: t1},
+	}
+
+	// The records of a measurement are samples of the same series, in
+	// time order, counted once against MaxSeries.
+	samples, dropped, err := kpi.LimitedSamples(Limits{MaxSeries: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	assert.Len(t, samples, 2)
+	assert.Equal(t, t1, samples[0].Timestamp)
+	assert.Equal(t, 1.0, samples[0].Value)
+	assert.Equal(t, t2, samples[1].Timestamp)
+	assert.Equal(t, samples[0].Labels, samples[1].Labels)
+}
@@ -5,15 +5,16 @@
 package kpis
 
 import (
-	"github.com/onosproject/onos-lib-go/pkg/prom"
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Var definitions of xapp pci metrics builder and static labels.
-// builder is used to create metrics in the PrometheusFormat.
+// builder is used to create the metric descs of the samples.
 var (
 	staticLabelsXappPci = map[string]string{"sdran": "xapppci"}
-	xappPciBuilder      = prom.NewBuilder("onos", "xapppci", staticLabelsXappPci)
+	xappPciBuilder      = newBuilder("onos", "xapppci")
 )
 
 type CellConflict struct {
@@ -23,72 +24,112 @@ type CellConflict struct {
 	ResolvedPci       string
 }
 
+// CellHistory defines the history of a cell kept by the pci collector:
+// the number of conflicts detected and resolved, the number of PCI
+// changes and the seconds since the last one.
+type CellHistory struct {
+	CellID             string
+	ConflictsDetected  uint64
+	ConflictsResolved  uint64
+	PciChanges         uint64
+	SinceLastPciChange float64
+}
+
 type CellInfo struct {
-	CellID        string
-	NodeID        string
-	CellType      string
-	CellPci       string
-	CellDlearfcn  float64
-	CellNeighbors string
+	CellID       string
+	NodeID       string
+	CellType     string
+	CellPci      string
+	CellDlearfcn float64
 }
 
-// xapppciNumConflicts defines the common data that can be used
-// to output the format of a KPI (e.g., PrometheusFormat).
-// CellInfo stores the cell info.
+// NeighborUnknown is the value of the SamePci and SameEarfcn flags
+// of a CellNeighbor whose neighbor is not a known cell.
+const NeighborUnknown = "unknown"
+
+// CellNeighbor defines the directed neighbor relation from the cell
+// CellID to the cell NeighborID. SamePci and SameEarfcn flag if both
+// cells have the same PCI and the same DL EARFCN, "true" or "false",
+// or NeighborUnknown.
+type CellNeighbor struct {
+	CellID     string
+	NeighborID string
+	SamePci    string
+	SameEarfcn string
+}
+
+// xappPciNumConflicts is the kpi of the cells known to the pci xapp,
+// stored by cell id in Cells with their PCI, EARFCN and type.
 type xappPciNumConflicts struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	Cells       map[string]CellInfo
 }
 
-// xappPciResolvedConflicts defines the common data that can be used
-// to output the format of a KPI (e.g., PrometheusFormat).
-// CellConflict stores the number of conflicts per cell id.
+// xappPciResolvedConflicts is the kpi of the conflicts resolved by
+// the pci xapp, stored by cell id in Cells.
 type xappPciResolvedConflicts struct {
-	name        string
-	description string
+	kpiBase
 	Labels      []string
 	LabelValues []string
 	Cells       map[string]CellConflict
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
-// interface for xapppciNumConflicts.
-func (c *xappPciNumConflicts) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+// xappPciHistory is the kpi of the conflicts and PCI changes of the
+// cells across the polls of the pci xapp. Cells stores the history per
+// cell id, and Unresolved the number of cells in conflict.
+type xappPciHistory struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Cells       map[string]CellHistory
+	Unresolved  int
+}
+
+// xappPciNeighbors is the kpi of the neighbor relations of the cells
+// of the pci xapp. Cells stores the neighbor relations per cell id.
+type xappPciNeighbors struct {
+	kpiBase
+	Labels      []string
+	LabelValues []string
+	Cells       map[string][]CellNeighbor
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for xappPciNumConflicts.
+func (c *xappPciNumConflicts) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
-	c.Labels = []string{"cellid", "celltype", "nodeid", "pci", "neighbors"}
+	c.Labels = []string{"cellid", "celltype", "nodeid", "pci", "dlearfcn"}
 	metricDesc := xappPciBuilder.NewMetricDesc(c.name, c.description, c.Labels, staticLabelsXappPci)
 
 	for _, cell := range c.Cells {
-		metric := xappPciBuilder.MustNewConstMetric(
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
-			cell.CellDlearfcn,
+			1,
 			cell.CellID,
 			cell.CellType,
 			cell.NodeID,
 			cell.CellPci,
-			cell.CellNeighbors,
+			strconv.FormatFloat(cell.CellDlearfcn, 'f', -1, 64),
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
 }
 
-// PrometheusFormat implements the contract behavior of the kpis.KPI
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
 // interface for xappPciResolvedConflicts.
-func (c *xappPciResolvedConflicts) PrometheusFormat() ([]prometheus.Metric, error) {
-	metrics := []prometheus.Metric{}
+func (c *xappPciResolvedConflicts) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
 
 	c.Labels = []string{"cellid", "original_pci", "resolved_pci"}
 	metricDesc := xappPciBuilder.NewMetricDesc(c.name, c.description, c.Labels, staticLabelsXappPci)
 
 	for _, cell := range c.Cells {
-		metric := xappPciBuilder.MustNewConstMetric(
+		l.add(
 			metricDesc,
 			prometheus.GaugeValue,
 			cell.ResolvedConflicts,
@@ -96,8 +137,69 @@ func (c *xappPciResolvedConflicts) PrometheusFormat() ([]prometheus.Metric, erro
 			cell.OriginalPci,
 			cell.ResolvedPci,
 		)
-		metrics = append(metrics, metric)
 	}
 
-	return metrics, nil
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for xappPciHistory.
+func (c *xappPciHistory) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	labels := []string{"cellid"}
+	detectedDesc := xappPciBuilder.NewMetricDesc(
+		"conflicts_detected_total",
+		"The number of PCI conflicts detected in the cell",
+		labels, staticLabelsXappPci)
+	resolvedDesc := xappPciBuilder.NewMetricDesc(
+		"conflicts_resolved_total",
+		"The number of PCI conflicts of the cell resolved",
+		labels, staticLabelsXappPci)
+	changesDesc := xappPciBuilder.NewMetricDesc(
+		"pci_changes_total",
+		"The number of changes of the resolved PCI of the cell",
+		labels, staticLabelsXappPci)
+	sinceDesc := xappPciBuilder.NewMetricDesc(
+		"pci_last_change_age_seconds",
+		"The seconds since the last change of the resolved PCI of the cell",
+		labels, staticLabelsXappPci)
+	unresolvedDesc := xappPciBuilder.NewMetricDesc(
+		"unresolved_conflicts",
+		"The number of cells with an unresolved PCI conflict",
+		[]string{}, staticLabelsXappPci)
+
+	for _, cell := range c.Cells {
+		l.add(detectedDesc, prometheus.CounterValue, float64(cell.ConflictsDetected), cell.CellID)
+		l.add(resolvedDesc, prometheus.CounterValue, float64(cell.ConflictsResolved), cell.CellID)
+		l.add(changesDesc, prometheus.CounterValue, float64(cell.PciChanges), cell.CellID)
+		l.add(sinceDesc, prometheus.GaugeValue, cell.SinceLastPciChange, cell.CellID)
+	}
+	l.add(unresolvedDesc, prometheus.GaugeValue, float64(c.Unresolved))
+
+	return l.samples()
+}
+
+// LimitedSamples implements the contract behavior of the kpis.LimitedKPI
+// interface for xappPciNeighbors.
+func (c *xappPciNeighbors) LimitedSamples(limits Limits) ([]Sample, int, error) {
+	l := newLimiter(limits)
+
+	neighborDesc := xappPciBuilder.NewMetricDesc(
+		"neighbor",
+		"The directed neighbor relations of the cell",
+		[]string{"cellid", "neighbor_id", "same_pci", "same_earfcn"}, staticLabelsXappPci)
+	countDesc := xappPciBuilder.NewMetricDesc(
+		"neighbors",
+		"The number of neighbors of the cell",
+		[]string{"cellid"}, staticLabelsXappPci)
+
+	for cellID, neighbors := range c.Cells {
+		for _, neighbor := range neighbors {
+			l.add(neighborDesc, prometheus.GaugeValue, 1, neighbor.CellID, neighbor.NeighborID, neighbor.SamePci, neighbor.SameEarfcn)
+		}
+		l.add(countDesc, prometheus.GaugeValue, float64(len(neighbors)), cellID)
+	}
+
+	return l.samples()
 }